@@ -22,12 +22,22 @@
 package ant
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"sort"
+	"sync"
 	"time"
 )
 
+// Driver is the transport to the module. Read may block until data arrives (preferably with a
+// short timeout so Stop isn't held up) or return 0 bytes right away when there is nothing,
+// the read loop then waits for the read backoff before polling again, see WithReadBackoff.
+// A read timing out is returned the same way, or as an error with a Timeout method returning
+// true; other read errors are reported on Errors.
 type Driver interface {
 	Open() error
 	Close()
@@ -36,219 +46,1022 @@ type Driver interface {
 	BufferSize() int
 }
 
+var (
+	ErrUnsupportedByDevice = errors.New("Operation not supported by the device")
+	ErrNetworkKeyNotSet    = errors.New("Network key not set")
+	ErrChannelNotAssigned  = errors.New("Channel not assigned")
+	ErrDeviceStopped       = errors.New("Device stopped")
+	ErrNetworkOutOfRange   = errors.New("Network not supported by the device")
+	ErrSearchTimeout       = errors.New("Search timed out")
+	ErrNoFreeChannel       = errors.New("No free channel")
+	ErrStopTimeout         = errors.New("Device did not stop in time")
+	ErrPeriodOutOfRange    = errors.New("Frequency out of the channel period range")
+	ErrChannelNotTransmit  = errors.New("Channel can't transmit")
+	ErrInvalidChannelType  = errors.New("Invalid channel type")
+	ErrAckNotConfirmed     = errors.New("Acknowledged data not confirmed")
+	ErrChannelInUse        = errors.New("Channel already assigned")
+)
+
+const (
+	errorsBufferSize   = 8
+	defaultReadBackoff = time.Millisecond
+	responseTimeout    = time.Second // How long helpers without a context wait for the module
+)
+
+// MessageHandler receives the decoded messages, see Run.
+type MessageHandler func(msg *Message)
+
+// Priority orders the messages waiting to be written to the driver. Whenever the loop
+// picks the next message to write, a queued message of a higher priority always goes out
+// before one of a lower priority, while messages of the same priority are written in the
+// order they were queued. Every write blocks until the loop has taken the message, so the
+// messages sent from a single goroutine always go out in program order, whatever their priority.
+type Priority uint8
+
+const (
+	PriorityControl Priority = iota // System and channel control, e.g. ResetSystem, OpenChannel
+	PriorityConfig                  // Channel and network configuration
+	PriorityData                    // Broadcast, acknowledged and burst data
+
+	priorityLevels
+)
+
 type Ant struct {
-	driver          Driver
-	buffer          Packet
-	read            chan *Message
-	write           chan *Message
-	writeInTimeslot chan *Message
-	stopper         chan struct{}
-	decoder         chan byte
-	done            chan struct{}
+	driver  Driver
+	buffer  Packet
+	read    chan *Message
+	handler MessageHandler
+	write   [priorityLevels]chan []*Message
+	stopper chan struct{}
+	stopped chan struct{} // Closed once the loop has exited and nothing gets written anymore
+	pause   chan bool
+	decoder chan Packet    // Chunks read from the driver
+	readers sync.WaitGroup // The read and decode loops
+	started bool           // Guarded by mu
+	paused  bool           // Guarded by mu, the state the read loop starts in
+	stop    sync.Once
+	errs    chan error
+
+	coalesceBursts bool
+	logger         *log.Logger
+	readBackoff    time.Duration
+	trace          *traceBuffer
+	overflow       OverflowPolicy
+	dedup          map[uint8]*dedupState // The channels set up WithDedup, fixed once made
+	autoReopen     map[uint8]bool
+	checkResponses bool
+
+	mu              sync.Mutex
+	capabilities    *Capabilities
+	eventHandlers   map[uint8][]*eventHandler
+	messageHandlers map[uint8][]*messageHandler
+	unhandled       func(*Message)
+	filter          map[uint8]bool
+	networks        map[uint8][8]uint8
+	channels        map[uint8]*ChannelConfig
+	txPower         *uint8 // Last transmit power set, nil after a reset
+	libConfig       uint8  // Last lib config set, 0 after a reset
+	resetPending    bool   // A reset was sent and its startup message hasn't arrived yet
+	unexpectedReset bool   // The last startup message wasn't caused by a reset sent
+	timing          map[uint8]*channelTiming
+	quality         map[uint8]*ChannelQuality
+	last            map[uint8]*Message       // Last data message of each channel
+	pages           map[uint8]map[uint8]bool // First payload bytes seen on each channel
+	reserved        map[uint8]bool           // Channels taken by helpers like ReadProfile, see freeChannel
+	requests        map[requestKey][]*pendingRequest
+	requestSeq      uint64 // Counts the requests made, to tell the oldest
 }
 
-func MakeAnt(dev Driver, read chan *Message) (ant *Ant) {
+func MakeAnt(dev Driver, read chan *Message, options ...Option) (ant *Ant) {
 	ant = &Ant{
-		driver: dev,
-		read:   read,
-		write:  make(chan *Message),
-		// writeInTimeslot: make(chan *Message),
-		stopper: make(chan struct{}),
-		decoder: make(chan byte),
-		done:    make(chan struct{}),
+		driver:      dev,
+		read:        read,
+		stopper:     make(chan struct{}),
+		stopped:     make(chan struct{}),
+		pause:       make(chan bool),
+		decoder:     make(chan Packet),
+		errs:        make(chan error, errorsBufferSize),
+		logger:      log.Default(),
+		readBackoff: defaultReadBackoff,
+	}
+
+	for i := range ant.write {
+		ant.write[i] = make(chan []*Message)
+	}
+
+	for _, option := range options {
+		option(ant)
+	}
+
+	if d, ok := dev.(interface{ SetLogger(*log.Logger) }); ok {
+		d.SetLogger(ant.logger)
 	}
 
 	return ant
 }
 
 func (dev *Ant) Start() (e error) {
-	log.Println("Starting Device")
+	dev.logger.Println("Starting Device")
 	e = dev.driver.Open()
 
 	if e != nil {
 		return e
 	}
 
-	dev.buffer = make(Packet, dev.driver.BufferSize())
+	size := dev.driver.BufferSize()
+	if size <= 0 {
+		dev.driver.Close()
+		return fmt.Errorf("Driver reported an invalid buffer size %d", size)
+	}
+
+	dev.buffer = make(Packet, size)
+	dev.mu.Lock()
+	dev.started = true
+	dev.mu.Unlock()
 
+	dev.readers.Add(2)
 	go dev.loop()
 	go dev.decodeLoop()
 	go dev.readLoop()
 	return e
 }
 
+// Stop stops the loops and closes the driver, once they have all exited no goroutine is left
+// behind. It can be called several times, only the first call does anything.
 func (dev *Ant) Stop() {
-	dev.stopper <- struct{}{}
-	dev.buffer = nil
+	dev.stop.Do(func() {
+		close(dev.stopper)
+
+		dev.mu.Lock()
+		started := dev.started
+		dev.mu.Unlock()
+		if !started {
+			return
+		}
+
+		// Stop writing before closing the driver, which in turn unblocks a pending read
+		<-dev.stopped
+		dev.driver.Close()
+		dev.readers.Wait()
+		dev.buffer = nil
+	})
+}
 
-	// Wait for loops to finish
-	<-dev.done
-	<-dev.done
+// StopTimeout is Stop giving up after d, e.g. when a misbehaving driver's Read never returns.
+// It then returns ErrStopTimeout and the loops are left to exit in the background, if ever;
+// a later Stop blocks until they do.
+func (dev *Ant) StopTimeout(d time.Duration) error {
+	stopped := make(chan struct{})
+	go func() {
+		dev.Stop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-timer.C:
+		return ErrStopTimeout
+	}
+}
+
+// Run starts the device and passes every decoded message to handler until ctx is done,
+// then stops the device and returns ctx.Err(). The handler is called synchronously from the
+// decoding goroutine: a slow handler holds back decoding, but no message is dropped.
+// Run takes the place of Start and Stop, which must not be called while it runs.
+func (dev *Ant) Run(ctx context.Context, handler MessageHandler) error {
+	dev.handler = handler
+
+	if err := dev.Start(); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	dev.Stop()
+
+	return ctx.Err()
+}
+
+// Messages returns the read channel, the one given to MakeAnt or created WithReadBuffer.
+// It is closed when the device stops.
+func (dev *Ant) Messages() <-chan *Message {
+	return dev.read
+}
+
+// Errors returns the channel on which errors from the background loops are reported, like
+// a failing driver write, after which the device shuts down and Stop should be called.
+// Errors are dropped when the channel's buffer is full.
+func (dev *Ant) Errors() <-chan error {
+	return dev.errs
+}
+
+func (dev *Ant) emitError(err error) {
+	dev.logger.Println(err)
+
+	select {
+	case dev.errs <- err:
+	default:
+	}
+}
+
+// recoverLoop turns a panic in one of the loops into an error, it must be deferred directly.
+func (dev *Ant) recoverLoop(name string) {
+	if r := recover(); r != nil {
+		dev.emitError(fmt.Errorf("%s panicked: %v", name, r))
+	}
+}
+
+// Pause suspends polling the driver for incoming data. The driver stays open and the
+// channel configuration on the module is left untouched, so reading can pick up again with Resume.
+// Called before Start, the device starts paused.
+func (dev *Ant) Pause() {
+	dev.setPaused(true)
+}
+
+// Resume restarts polling the driver after a Pause.
+func (dev *Ant) Resume() {
+	dev.setPaused(false)
+}
+
+func (dev *Ant) setPaused(paused bool) {
+	dev.mu.Lock()
+	dev.paused = paused
+	started := dev.started
+	dev.mu.Unlock()
+
+	if !started {
+		// The read loop picks the state up when it starts
+		return
+	}
+
+	select {
+	case dev.pause <- paused:
+	case <-dev.stopper:
+	}
 }
 
 func (dev *Ant) loop() {
 
 	// ticker := time.NewTicker(time.Millisecond)
-	defer func() { dev.done <- struct{}{} }()
-	defer dev.driver.Close()
-	defer close(dev.decoder)
-	defer close(dev.write)
+	defer close(dev.stopped)
 	// defer ticker.Stop()
-	// defer close(dev.writeInTimeslot)
-	defer log.Println("Loop stopped!")
+	defer dev.logger.Println("Loop stopped!")
+	defer dev.recoverLoop("Loop")
 
-	log.Println("Loop Started")
+	dev.logger.Println("Loop Started")
 
 	for {
+		var d []*Message
+
+		// Only fall through to a lower priority once the higher ones have nothing queued
 		select {
 		case <-dev.stopper:
 			return
+		case d = <-dev.write[PriorityControl]:
+		default:
+			select {
+			case <-dev.stopper:
+				return
+			case d = <-dev.write[PriorityControl]:
+			case d = <-dev.write[PriorityConfig]:
+			default:
+				select {
+				case <-dev.stopper:
+					return
+				case d = <-dev.write[PriorityControl]:
+				case d = <-dev.write[PriorityConfig]:
+				case d = <-dev.write[PriorityData]:
+				}
+			}
+		}
+
+		if err := dev.writeMessages(d); err != nil {
+			// Give up on a failing driver, Stop still has to be called to clean up
+			dev.emitError(err)
+			return
+		}
+	}
+}
 
-		case d := <-dev.write:
-			m := d.Encode()
+// writeMessages writes a batch of messages, packing as many frames into each driver write
+// as the driver's buffer size allows.
+func (dev *Ant) writeMessages(messages []*Message) error {
+	size := dev.driver.BufferSize()
+	var chunk Packet
+
+	for _, d := range messages {
+		m := d.Encode()
+		dev.logger.Println("Writing: ", m)
+		if dev.trace != nil {
+			dev.trace.record(true, m)
+		}
 
-			log.Println("Writing: ", m)
-			_, err := dev.driver.Write(m)
-			if err != nil {
-				log.Println(err)
+		if len(chunk) > 0 && len(chunk)+len(m) > size {
+			if err := dev.writeChunk(chunk); err != nil {
+				return err
 			}
-			time.Sleep(time.Nanosecond)
+			chunk = chunk[:0]
+		}
+		chunk = append(chunk, m...)
+	}
+
+	return dev.writeChunk(chunk)
+}
+
+// writeChunk writes the whole chunk, calling the driver again after a short write. A write that
+// makes no progress without an error fails with io.ErrShortWrite rather than spinning.
+func (dev *Ant) writeChunk(chunk Packet) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+
+	for written := 0; written < len(chunk); {
+		n, err := dev.driver.Write(chunk[written:])
+		if err == nil && n <= 0 {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return fmt.Errorf("Could not write %s (%d of %d bytes written): %w", chunk, written, len(chunk), err)
 		}
+		written += n
 	}
+	time.Sleep(time.Nanosecond)
+	return nil
+}
+
+// queueContext hands a message to the loop to be written with the given priority, giving up
+// when ctx is done
+func (dev *Ant) queueContext(ctx context.Context, priority Priority, message *Message) error {
+	return dev.queueBatchContext(ctx, priority, []*Message{message})
+}
+
+// queueBatch hands several messages to the loop, to be written back to back
+func (dev *Ant) queueBatch(priority Priority, messages []*Message) {
+	_ = dev.queueBatchContext(context.Background(), priority, messages)
+}
+
+func (dev *Ant) queueBatchContext(ctx context.Context, priority Priority, messages []*Message) error {
+	// Encoding happens in the loop, where it must not panic
+	for _, message := range messages {
+		if len(message.Data) > MaxPayloadSize {
+			return fmt.Errorf("Payload length should be at most %d not %d", MaxPayloadSize, len(message.Data))
+		}
+	}
+
+	// A reset is expected before the loop takes it, a fast module may answer before we are back
+	var reset bool
+	for _, message := range messages {
+		reset = reset || message.Id == MESG_SYSTEM_RESET_ID
+	}
+
+	// Nothing would ever take the messages before Start
+	dev.mu.Lock()
+	started := dev.started
+	resetPending := dev.resetPending
+	if started && reset {
+		dev.resetPending = true
+	}
+	dev.mu.Unlock()
+	if !started {
+		return ErrDeviceStopped
+	}
+
+	var err error
+	select {
+	case dev.write[priority] <- messages:
+	case <-dev.stopper:
+		err = ErrDeviceStopped
+	case <-dev.stopped:
+		err = ErrDeviceStopped
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		dev.mu.Lock()
+		dev.resetPending = resetPending
+		dev.mu.Unlock()
+		return err
+	}
+
+	for _, message := range messages {
+		dev.trackConfig(message)
+	}
+	return nil
 }
 
 func (dev *Ant) readLoop() {
-	ticker := time.NewTicker(time.Millisecond)
+	timer := time.NewTimer(0)
 
-	defer ticker.Stop()
+	dev.mu.Lock()
+	paused := dev.paused
+	dev.mu.Unlock()
+
+	defer dev.readers.Done()
+	defer close(dev.decoder)
+	defer timer.Stop()
+	defer dev.recoverLoop("Read loop")
+
+	// Read errors are reported once until a read succeeds, not on every poll
+	var lastErr string
 
 	for {
+		// While paused the timer isn't re-armed, so the loop sleeps until resumed or stopped
+		var poll <-chan time.Time
+		if !paused {
+			poll = timer.C
+		}
+
 		select {
-		case <-ticker.C:
-			if i, err := dev.driver.Read(dev.buffer); err == nil {
-				for _, v := range dev.buffer[:i] {
-					dev.decoder <- v
+		case <-dev.stopper:
+			return
 
+		case p := <-dev.pause:
+			if p && !paused {
+				timer.Stop()
+			} else if !p && paused {
+				timer.Reset(0)
+			}
+			paused = p
+
+		case <-poll:
+			// Poll again right away while data is coming in, back off when idle
+			wait := dev.readBackoff
+
+			if dev.stopping() {
+				return
+			}
+
+			i, err := dev.driver.Read(dev.buffer)
+			switch {
+			case err != nil && !isTimeout(err):
+				if err.Error() != lastErr {
+					lastErr = err.Error()
+					dev.emitError(fmt.Errorf("Could not read: %w", err))
 				}
+
+			case i > 0:
+				lastErr = ""
+
+				// The buffer is reused by the next read
+				chunk := append(Packet(nil), dev.buffer[:i]...)
+
+				select {
+				case dev.decoder <- chunk:
+				case <-dev.stopper:
+					return
+				}
+				wait = 0
 			}
+
+			timer.Reset(wait)
 		}
 	}
+}
 
+// isTimeout tells if a driver error only means that no data came in time, as told by a
+// Timeout method like that of net.Error.
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// stopping tells if Stop has been called.
+func (dev *Ant) stopping() bool {
+	select {
+	case <-dev.stopper:
+		return true
+	default:
+		return false
+	}
 }
 
 func (dev *Ant) decodeLoop() {
-	defer func() { dev.done <- struct{}{} }()
-	defer close(dev.read)
+	defer dev.readers.Done()
+	defer func() {
+		if dev.read != nil {
+			close(dev.read)
+		}
+	}()
+	defer dev.recoverLoop("Decode loop")
 
-	for {
-		// Wait for TX Sync
-		if sync, ok := <-dev.decoder; !ok {
-			return
-		} else if sync != MESG_TX_SYNC {
-			continue
+	var decoder Decoder
+
+	for chunk := range dev.decoder {
+		decoder.Write(chunk)
+
+		for {
+			frame, msg, err := decoder.next()
+			if frame == nil {
+				break
+			}
+
+			if dev.trace != nil {
+				dev.trace.record(false, frame)
+			}
+
+			// Check message integrity
+			if err != nil {
+				dev.logger.Println(err)
+				continue
+			}
+			msg.Received = time.Now()
+
+			dev.logger.Println("Read:", msg)
+			dev.handle(msg)
 		}
+	}
+}
 
-		// Get content length (+1byte type + 1byte checksum)
-		length, ok := <-dev.decoder
-		if !ok {
-			continue
+// handle processes a decoded message, a panic in a user handler is reported on Errors
+// instead of bringing down the decoding goroutine.
+func (dev *Ant) handle(msg *Message) {
+	defer dev.recoverLoop("Message handler")
+
+	dev.process(msg)
+	dev.dispatch(msg)
+	dev.deliver(msg)
+}
+
+// deliver hands a message to the user, either to the handler given to Run or the read channel.
+// When the read channel is full the OverflowPolicy applies, by default dropping the message
+// so that the loops never stall on the user.
+func (dev *Ant) deliver(msg *Message) {
+	if !dev.passesFilter(msg) || dev.isDuplicate(msg) {
+		return
+	}
+
+	if dev.handler != nil {
+		dev.handler(msg)
+	}
+
+	if dev.read == nil {
+		return
+	}
+
+	switch dev.overflow {
+	case Block:
+		select {
+		case dev.read <- msg:
+		case <-dev.stopper:
 		}
 
-		buf := make(Packet, length+4)
-		buf[0] = MESG_TX_SYNC
-		buf[1] = length
-		for i := 2; i < int(length+4); i++ {
-			if buf[i], ok = <-dev.decoder; !ok {
+	case DropOldest:
+		if cap(dev.read) == 0 {
+			// Nothing to drop from an unbuffered channel
+			select {
+			case dev.read <- msg:
+			default:
+			}
+			return
+		}
+
+		for {
+			select {
+			case dev.read <- msg:
 				return
+			default:
+			}
+
+			// Make room, unless the reader just did
+			select {
+			case <-dev.read:
+			default:
 			}
 		}
 
-		// Check message integrity
-		msg, err := Decode(buf)
-		if err != nil {
-			log.Println(err)
-			continue
+	default:
+		select {
+		case dev.read <- msg:
+		default:
 		}
+	}
+}
+
+// process updates the device state from a received message
+func (dev *Ant) process(msg *Message) {
+	dev.answerRequest(msg)
 
-		log.Println("Read:", msg)
-		// if dev.read != nil {
-		// 	dev.read <- msg
-		//
-		// }
+	switch msg.Id {
+	case MESG_CAPABILITIES_ID:
+		if c, ok := msg.AsCapabilities(); ok {
+			dev.mu.Lock()
+			dev.capabilities = &c
+			dev.mu.Unlock()
+		}
+
+	case MESG_STARTUP_MESG_ID:
+		dev.mu.Lock()
+		dev.unexpectedReset = !dev.resetPending
+		dev.resetPending = false
+		dev.mu.Unlock()
+
+	case MESG_RESPONSE_EVENT_ID:
+		dev.trackQuality(msg)
+		dev.reopen(msg)
+		dev.checkResponse(msg)
+
+	case MESG_BROADCAST_DATA_ID, MESG_ACKNOWLEDGED_DATA_ID, MESG_EXT_BROADCAST_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID:
+		dev.trackTiming(msg)
+		dev.trackQuality(msg)
+
+		dev.mu.Lock()
+		if dev.last == nil {
+			dev.last = make(map[uint8]*Message)
+			dev.pages = make(map[uint8]map[uint8]bool)
+		}
+		dev.last[msg.Channel()] = msg
+		_, payload, ok := msg.AsBroadcastData()
+		if !ok {
+			_, payload, ok = msg.AsAcknowledgedData()
+		}
+		if ok {
+			if dev.pages[msg.Channel()] == nil {
+				dev.pages[msg.Channel()] = make(map[uint8]bool)
+			}
+			dev.pages[msg.Channel()][payload[0]] = true
+		}
+		dev.mu.Unlock()
+	}
+}
+
+// checkResponse logs the rejected commands when the Ant was made WithResponseChecking.
+func (dev *Ant) checkResponse(msg *Message) {
+	if !dev.checkResponses {
+		return
+	}
+
+	if e, ok := msg.AsChannelEvent(); ok && !e.IsRFEvent() && e.Code != RESPONSE_NO_ERROR {
+		dev.logger.Printf("Warning: %s on channel %d rejected with %s", MessageName(e.MessageID), e.Channel, ResponseCodeName(e.Code))
+	}
+}
+
+// reopen opens a channel set up WithAutoReopen again when the module reports it closed without
+// having been told to close it.
+func (dev *Ant) reopen(msg *Message) {
+	e, ok := msg.AsChannelEvent()
+	if !ok || !e.IsRFEvent() || e.Code != EVENT_CHANNEL_CLOSED || !dev.autoReopen[e.Channel] {
+		return
+	}
 
-		// select {
-		// case dev.read <- msg:
-		// default:
-		// }
+	dev.mu.Lock()
+	c, assigned := dev.channels[e.Channel]
+	open := assigned && c.Open
+	dev.mu.Unlock()
 
+	if open {
+		dev.logger.Println("Reopening channel", e.Channel)
+		dev.OpenChannel(e.Channel)
 	}
 }
 
+// LastMessage returns the last broadcast or acknowledged data message received on the channel,
+// e.g. for a dashboard showing the latest value. It is kept whatever the filters, ok is false
+// if nothing has been received on the channel yet. The message must not be modified.
+// Like the other per-channel statistics it is dropped when the channel is assigned, unassigned
+// or given another channel ID, so a reused channel doesn't report the previous device.
+func (dev *Ant) LastMessage(channel uint8) (msg *Message, ok bool) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	msg, ok = dev.last[channel]
+	return msg, ok
+}
+
+// ObservedPages returns the distinct data page numbers, the first payload byte, received on the
+// channel in broadcast or acknowledged data, in ascending order. It helps finding out which pages
+// an unfamiliar sensor sends. Pages are reported as sent, with the toggle bit of the profiles
+// using one (see antplus.PageToggleBit).
+func (dev *Ant) ObservedPages(channel uint8) []uint8 {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	pages := make([]uint8, 0, len(dev.pages[channel]))
+	for page := range dev.pages[channel] {
+		pages = append(pages, page)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	return pages
+}
+
+// FilterChannels restricts the messages delivered to the read channel or Run handler to those
+// of the given channels. Messages not tied to a channel, like the startup message, are always
+// delivered. Calling it without channels removes the filter, delivering everything again.
+func (dev *Ant) FilterChannels(channels ...uint8) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if len(channels) == 0 {
+		dev.filter = nil
+		return
+	}
+
+	dev.filter = make(map[uint8]bool, len(channels))
+	for _, c := range channels {
+		dev.filter[c] = true
+	}
+}
+
+func (dev *Ant) passesFilter(msg *Message) bool {
+	if !msg.isChannelMessage() {
+		return true
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	return dev.filter == nil || dev.filter[msg.Channel()]
+}
+
+// dedupState is the last broadcast payload of a channel set up WithDedup, guarded by dev.mu.
+type dedupState struct {
+	payload [8]byte
+	seen    bool // payload is set, false until the first broadcast and after forgetDevice
+}
+
+// isDuplicate tells if msg is a broadcast repeating the previous payload on a channel set up WithDedup.
+// Only the payload is compared, not the channel ID of legacy extended messages nor the extended data.
+func (dev *Ant) isDuplicate(msg *Message) bool {
+	state, ok := dev.dedup[msg.Channel()]
+	if !ok {
+		return false
+	}
+
+	_, payload, ok := msg.AsBroadcastData()
+	if !ok {
+		return false
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if state.seen && state.payload == payload {
+		return true
+	}
+	state.payload, state.seen = payload, true
+	return false
+}
+
 // //////////////////////////////////////////////////////////////////////////////////////
 // Config Messages
+// The config and control methods block until the loop takes the message, their XxxContext
+// variants give up when ctx is done and return ErrDeviceStopped when the device isn't running.
 // //////////////////////////////////////////////////////////////////////////////////////
 
 func (dev *Ant) UnAssignChannel(channel uint8) {
+	_ = dev.UnAssignChannelContext(context.Background(), channel)
+}
+
+// UnAssignChannelContext is UnAssignChannel giving up when ctx is done.
+func (dev *Ant) UnAssignChannelContext(ctx context.Context, channel uint8) error {
 	message := NewMessage(MESG_UNASSIGN_CHANNEL_ID, Packet{channel})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// Channel types taken by AssignChannel. The type is a bitfield of PARAMETER_TX_NOT_RX (master),
+// PARAMETER_SHARED_CHANNEL and PARAMETER_RX_ONLY, these are its valid combinations.
+const (
+	ChannelTypeBidirectionalSlave        = PARAMETER_RX_NOT_TX
+	ChannelTypeBidirectionalMaster       = PARAMETER_TX_NOT_RX
+	ChannelTypeSharedBidirectionalSlave  = PARAMETER_SHARED_CHANNEL | PARAMETER_RX_NOT_TX
+	ChannelTypeSharedBidirectionalMaster = PARAMETER_SHARED_CHANNEL | PARAMETER_TX_NOT_RX
+	ChannelTypeSlaveReceiveOnly          = PARAMETER_RX_ONLY | PARAMETER_RX_NOT_TX
+	ChannelTypeMasterTransmitOnly        = PARAMETER_RX_ONLY | PARAMETER_TX_NOT_RX
+)
+
+// checkChannelType returns ErrInvalidChannelType if channelType isn't one of the ChannelType constants.
+func checkChannelType(channelType uint8) error {
+	switch channelType {
+	case ChannelTypeBidirectionalSlave, ChannelTypeBidirectionalMaster,
+		ChannelTypeSharedBidirectionalSlave, ChannelTypeSharedBidirectionalMaster,
+		ChannelTypeSlaveReceiveOnly, ChannelTypeMasterTransmitOnly:
+		return nil
+	}
+	return fmt.Errorf("%w: 0x%02X", ErrInvalidChannelType, channelType)
+}
+
+// AssignChannel assigns the channel to a network. Network 0 works with the module's default
+// public key, any other network must have had its key set with SetNetworkKey first, otherwise
+// ErrNetworkKeyNotSet is returned and nothing is sent. channelType is one of the ChannelType
+// constants, any other value returns ErrInvalidChannelType.
+func (dev *Ant) AssignChannel(channel uint8, channelType uint8, networkNumber uint8) error {
+	return dev.AssignChannelContext(context.Background(), channel, channelType, networkNumber)
 }
 
-func (dev *Ant) AssignChannel(channel uint8, channelType uint8, networkNumber uint8) {
+// AssignChannelContext is AssignChannel giving up when ctx is done.
+func (dev *Ant) AssignChannelContext(ctx context.Context, channel uint8, channelType uint8, networkNumber uint8) error {
+	if err := checkChannelType(channelType); err != nil {
+		return err
+	}
+	if err := dev.checkNetwork(networkNumber); err != nil {
+		return err
+	}
+
 	message := NewMessage(MESG_ASSIGN_CHANNEL_ID, Packet{channel, channelType, networkNumber})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
-func (dev *Ant) AssignChannelExt(channel uint8, channelType uint8, networkNumber uint8, ExtFlags uint8) {
+// AssignChannelExt is AssignChannel with extended assignment flags, an OR of EXT_PARAM_* constants,
+// e.g. EXT_PARAM_BACKGROUND_SCANNING|EXT_PARAM_FAST_CHANNEL_INIT.
+func (dev *Ant) AssignChannelExt(channel uint8, channelType uint8, networkNumber uint8, ExtFlags uint8) error {
+	return dev.AssignChannelExtContext(context.Background(), channel, channelType, networkNumber, ExtFlags)
+}
+
+// AssignChannelExtContext is AssignChannelExt giving up when ctx is done.
+func (dev *Ant) AssignChannelExtContext(ctx context.Context, channel uint8, channelType uint8, networkNumber uint8, ExtFlags uint8) error {
+	if err := checkChannelType(channelType); err != nil {
+		return err
+	}
+	if err := dev.checkNetwork(networkNumber); err != nil {
+		return err
+	}
+
 	message := NewMessage(MESG_ASSIGN_CHANNEL_ID, Packet{channel, channelType, networkNumber, ExtFlags})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// checkChannel returns ErrChannelNotAssigned if the channel hasn't been assigned,
+// or doesn't exist on the module.
+func (dev *Ant) checkChannel(channel uint8) error {
+	dev.mu.Lock()
+	_, ok := dev.channels[channel]
+	dev.mu.Unlock()
+
+	if c, known := dev.Capabilities(); known && channel >= c.MaxChannels {
+		return fmt.Errorf("%w: the module has %d channels, not %d", ErrChannelNotAssigned, c.MaxChannels, channel+1)
+	}
+	if !ok {
+		return fmt.Errorf("%w: channel %d", ErrChannelNotAssigned, channel)
+	}
+	return nil
+}
+
+// checkTransmit returns ErrChannelNotTransmit if the channel was assigned receive only. Slave
+// channels otherwise can send data back to their master, so they aren't refused.
+func (dev *Ant) checkTransmit(channel uint8) error {
+	dev.mu.Lock()
+	c, ok := dev.channels[channel]
+	rxOnly := ok && c.Type&PARAMETER_RX_ONLY != 0
+	dev.mu.Unlock()
+
+	if rxOnly {
+		return fmt.Errorf("%w: channel %d is receive only", ErrChannelNotTransmit, channel)
+	}
+	return nil
+}
+
+func (dev *Ant) checkNetwork(networkNumber uint8) error {
+	if networkNumber == 0 {
+		return nil
+	}
+
+	dev.mu.Lock()
+	_, ok := dev.networks[networkNumber]
+	dev.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: network %d", ErrNetworkKeyNotSet, networkNumber)
+	}
+	return nil
 }
 
+// SetChannelId sets the channel ID the channel will search for (slave) or transmit with (master).
+// The device number is sent little-endian. On a slave channel a zero deviceNum, deviceType or
+// transmissionType acts as a wildcard, matching any value in that field, which is how devices get paired.
 func (dev *Ant) SetChannelId(channel uint8, deviceNum uint16, deviceType uint8, transmissionType uint8) {
+	_ = dev.SetChannelIdContext(context.Background(), channel, deviceNum, deviceType, transmissionType)
+}
+
+// SetChannelIdContext is SetChannelId giving up when ctx is done.
+func (dev *Ant) SetChannelIdContext(ctx context.Context, channel uint8, deviceNum uint16, deviceType uint8, transmissionType uint8) error {
 	payload := [5]byte{channel, 0, 0, deviceType, transmissionType}
-	binary.LittleEndian.PutUint16(payload[1:], uint16(deviceNum))
+	binary.LittleEndian.PutUint16(payload[1:], deviceNum)
 
 	message := NewMessage(MESG_CHANNEL_ID_ID, payload[:])
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// SetChannelIdWildcard makes a slave channel pair with any device of the given type.
+func (dev *Ant) SetChannelIdWildcard(channel uint8, deviceType uint8) {
+	_ = dev.SetChannelIdWildcardContext(context.Background(), channel, deviceType)
+}
+
+// SetChannelIdWildcardContext is SetChannelIdWildcard giving up when ctx is done.
+func (dev *Ant) SetChannelIdWildcardContext(ctx context.Context, channel uint8, deviceType uint8) error {
+	return dev.SetChannelIdContext(ctx, channel, 0, deviceType, 0)
+}
+
+// ClearChannelId makes a slave channel pair with any device.
+func (dev *Ant) ClearChannelId(channel uint8) {
+	_ = dev.ClearChannelIdContext(context.Background(), channel)
+}
+
+// ClearChannelIdContext is ClearChannelId giving up when ctx is done.
+func (dev *Ant) ClearChannelIdContext(ctx context.Context, channel uint8) error {
+	return dev.SetChannelIdContext(ctx, channel, 0, 0, 0)
 }
 
 func (dev *Ant) SetChannelPeriod(channel uint8, messagePeriod uint16) {
+	_ = dev.SetChannelPeriodContext(context.Background(), channel, messagePeriod)
+}
+
+// SetChannelPeriodContext is SetChannelPeriod giving up when ctx is done.
+func (dev *Ant) SetChannelPeriodContext(ctx context.Context, channel uint8, messagePeriod uint16) error {
 	payload := [3]byte{channel, 0, 0}
-	binary.LittleEndian.PutUint16(payload[1:], uint16(messagePeriod))
+	binary.LittleEndian.PutUint16(payload[1:], messagePeriod)
 
 	message := NewMessage(MESG_CHANNEL_MESG_PERIOD_ID, payload[:])
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// SetChannelPeriodHz sets the channel period from a message rate, e.g. 4.06Hz gives 8070 counts.
+// A rate that does not fit a period (roughly 0.5Hz to 32768Hz) returns ErrPeriodOutOfRange and
+// nothing is sent.
+func (dev *Ant) SetChannelPeriodHz(channel uint8, hz float64) error {
+	return dev.SetChannelPeriodHzContext(context.Background(), channel, hz)
+}
+
+// SetChannelPeriodHzContext is SetChannelPeriodHz giving up when ctx is done.
+func (dev *Ant) SetChannelPeriodHzContext(ctx context.Context, channel uint8, hz float64) error {
+	period, ok := periodFromHz(hz)
+	if !ok {
+		return fmt.Errorf("%w: %gHz", ErrPeriodOutOfRange, hz)
+	}
+
+	return dev.SetChannelPeriodContext(ctx, channel, period)
+}
+
+// SetChannelSearchTimeout sets how long the channel searches, in 2.5s units, before giving up
+// with EVENT_RX_SEARCH_TIMEOUT. Note that SearchTimeoutDisabled (0) does not mean infinite, it
+// makes the search time out right away; use SearchTimeoutInfinite (0xFF) to search forever,
+// or SearchTimeoutFromDuration to convert a duration.
+func (dev *Ant) SetChannelSearchTimeout(channel uint8, timeout uint8) {
+	_ = dev.SetChannelSearchTimeoutContext(context.Background(), channel, timeout)
 }
 
-func (dev *Ant) SetChannelSearchTimeout(channel uint8, messagePeriod uint8) {
-	message := NewMessage(MESG_CHANNEL_SEARCH_TIMEOUT_ID, Packet{channel, messagePeriod})
-	dev.write <- message
+// SetChannelSearchTimeoutContext is SetChannelSearchTimeout giving up when ctx is done.
+func (dev *Ant) SetChannelSearchTimeoutContext(ctx context.Context, channel uint8, timeout uint8) error {
+	message := NewMessage(MESG_CHANNEL_SEARCH_TIMEOUT_ID, Packet{channel, timeout})
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
+// SetChannelRFFreq sets the channel frequency as an offset from 2400MHz, e.g. RFFreqANTPlus (57)
+// for 2457MHz. Use RFFreqFromMHz to convert a frequency in MHz.
 func (dev *Ant) SetChannelRFFreq(channel uint8, rfFreq uint8) {
+	_ = dev.SetChannelRFFreqContext(context.Background(), channel, rfFreq)
+}
+
+// SetChannelRFFreqContext is SetChannelRFFreq giving up when ctx is done.
+func (dev *Ant) SetChannelRFFreqContext(ctx context.Context, channel uint8, rfFreq uint8) error {
 	message := NewMessage(MESG_CHANNEL_RADIO_FREQ_ID, Packet{channel, rfFreq})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
-func (dev *Ant) SetNetworkKey(channel uint8, key [8]uint8) {
-	payload := [9]byte{channel}
+// SetNetworkKey sets the key of a network. Once the capabilities are known (see RequestCapabilities)
+// a network the module doesn't have returns ErrNetworkOutOfRange and nothing is sent.
+func (dev *Ant) SetNetworkKey(networkNumber uint8, key [8]uint8) error {
+	return dev.SetNetworkKeyContext(context.Background(), networkNumber, key)
+}
+
+// SetNetworkKeyContext is SetNetworkKey giving up when ctx is done.
+func (dev *Ant) SetNetworkKeyContext(ctx context.Context, networkNumber uint8, key [8]uint8) error {
+	if c, known := dev.Capabilities(); known && networkNumber >= c.MaxNetworks {
+		return fmt.Errorf("%w: the module has %d networks, not %d", ErrNetworkOutOfRange, c.MaxNetworks, networkNumber+1)
+	}
+
+	payload := [9]byte{networkNumber}
 	copy(payload[1:], key[:])
 	message := NewMessage(MESG_NETWORK_KEY_ID, payload[:])
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
+// SetTransmitPower sets the transmit power of all the channels, one of RADIO_TX_POWER_LVL_0
+// (lowest) to RADIO_TX_POWER_LVL_3 (highest). It can be read back with TransmitPower.
 func (dev *Ant) SetTransmitPower(power uint8) {
-	message := NewMessage(MESG_CHANNEL_RADIO_TX_POWER_ID, Packet{0, power & RADIO_TX_POWER_LVL_MASK})
-	dev.write <- message
+	_ = dev.SetTransmitPowerContext(context.Background(), power)
+}
+
+// SetTransmitPowerContext is SetTransmitPower giving up when ctx is done.
+func (dev *Ant) SetTransmitPowerContext(ctx context.Context, power uint8) error {
+	message := NewMessage(MESG_RADIO_TX_POWER_ID, Packet{0, power & RADIO_TX_POWER_LVL_MASK})
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
 func (dev *Ant) SetSearchWaveform(channel uint8, searchWaveform uint16) {
+	_ = dev.SetSearchWaveformContext(context.Background(), channel, searchWaveform)
+}
+
+// SetSearchWaveformContext is SetSearchWaveform giving up when ctx is done.
+func (dev *Ant) SetSearchWaveformContext(ctx context.Context, channel uint8, searchWaveform uint16) error {
 	if searchWaveform != 316 && searchWaveform != 97 {
 		panic("The search waveform to be set. One of these two values only. (316 or 97)")
 	}
 	payload := [3]byte{channel}
-	binary.LittleEndian.PutUint16(payload[1:], uint16(searchWaveform))
-	message := NewMessage(MESG_RADIO_TX_POWER_ID, payload[:])
-	dev.write <- message
+	binary.LittleEndian.PutUint16(payload[1:], searchWaveform)
+	message := NewMessage(MESG_SEARCH_WAVEFORM_ID, payload[:])
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
 // //////////////////////////////////////////////////////////////////////////////////////
@@ -256,88 +1069,386 @@ func (dev *Ant) SetSearchWaveform(channel uint8, searchWaveform uint16) {
 // //////////////////////////////////////////////////////////////////////////////////////
 
 func (dev *Ant) ResetSystem() {
+	_ = dev.ResetSystemContext(context.Background())
+}
+
+// ResetSystemContext is ResetSystem giving up when ctx is done.
+func (dev *Ant) ResetSystemContext(ctx context.Context) error {
 	message := NewMessage(MESG_SYSTEM_RESET_ID, Packet{0})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityControl, message)
 }
 
 func (dev *Ant) OpenChannel(channel uint8) {
+	_ = dev.OpenChannelContext(context.Background(), channel)
+}
+
+// OpenChannelContext is OpenChannel giving up when ctx is done.
+func (dev *Ant) OpenChannelContext(ctx context.Context, channel uint8) error {
 	message := NewMessage(MESG_OPEN_CHANNEL_ID, Packet{channel})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityControl, message)
 }
 
 func (dev *Ant) CloseChannel(channel uint8) {
+	_ = dev.CloseChannelContext(context.Background(), channel)
+}
+
+// CloseChannelContext is CloseChannel giving up when ctx is done.
+func (dev *Ant) CloseChannelContext(ctx context.Context, channel uint8) error {
 	message := NewMessage(MESG_CLOSE_CHANNEL_ID, Packet{channel})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityControl, message)
+}
+
+// CloseChannelSync closes the channel and waits for the module to report EVENT_CHANNEL_CLOSED,
+// after which the channel can be unassigned. It returns the error of ctx if it's done first,
+// or a ResponseError if the module rejected the command, e.g. because the channel wasn't open.
+func (dev *Ant) CloseChannelSync(ctx context.Context, channel uint8) error {
+	result := make(chan error, 1)
+
+	remove := dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		var err error
+
+		switch {
+		case e.IsRFEvent() && e.Code == EVENT_CHANNEL_CLOSED:
+		case e.MessageID == MESG_CLOSE_CHANNEL_ID && e.Code != RESPONSE_NO_ERROR:
+			err = ResponseError(e)
+		default:
+			return
+		}
+
+		select {
+		case result <- err:
+		default:
+		}
+	})
+	defer remove()
+
+	message := NewMessage(MESG_CLOSE_CHANNEL_ID, Packet{channel})
+	if err := dev.queueContext(ctx, PriorityControl, message); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OpenChannelSync opens the channel and waits for the module's response. It returns a ResponseError
+// if the module rejected the command, e.g. CHANNEL_IN_WRONG_STATE for a channel that isn't assigned
+// or is already open, or the error of ctx if it's done first.
+func (dev *Ant) OpenChannelSync(ctx context.Context, channel uint8) error {
+	message := NewMessage(MESG_OPEN_CHANNEL_ID, Packet{channel})
+	return dev.commandSync(ctx, PriorityControl, channel, message)
+}
+
+// commandSync sends a command and waits for the response event of the module.
+func (dev *Ant) commandSync(ctx context.Context, priority Priority, channel uint8, message *Message) error {
+	result := make(chan error, 1)
+
+	remove := dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if e.MessageID != message.Id {
+			return
+		}
+
+		var err error
+		if e.Code != RESPONSE_NO_ERROR {
+			err = ResponseError(e)
+		}
+
+		select {
+		case result <- err:
+		default:
+		}
+	})
+	defer remove()
+
+	if err := dev.queueContext(ctx, priority, message); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (dev *Ant) RequestMessage(channel uint8, messageId uint8) {
-	message := NewMessage(MESG_REQUEST_SIZE, Packet{channel, messageId})
-	dev.write <- message
+	_ = dev.RequestMessageContext(context.Background(), channel, messageId)
+}
+
+// RequestMessageContext is RequestMessage giving up when ctx is done.
+func (dev *Ant) RequestMessageContext(ctx context.Context, channel uint8, messageId uint8) error {
+	message := NewMessage(MESG_REQUEST_ID, Packet{channel, messageId})
+	return dev.queueContext(ctx, PriorityControl, message)
+}
+
+// WriteMessage sends a raw message. It returns ErrDeviceStopped if the device isn't started, was
+// stopped, or its loop gave up on a failing driver, in which case it has to be restarted.
+func (dev *Ant) WriteMessage(messageID uint8, data Packet) error {
+	return dev.WriteRaw(NewMessage(messageID, data))
 }
 
-func (dev *Ant) WriteMessage(messageID uint8, data Packet) {
-	message := NewMessage(messageID, data)
-	dev.write <- message
+// WriteRaw queues a pre-built message, for messages without a dedicated method.
+// It returns ErrDeviceStopped like WriteMessage.
+func (dev *Ant) WriteRaw(m *Message) error {
+	return dev.WriteRawContext(context.Background(), PriorityConfig, m)
+}
+
+// WriteRawContext is WriteRaw with a priority, giving up with ctx.Err() if ctx is done
+// before the loop takes the message.
+func (dev *Ant) WriteRawContext(ctx context.Context, priority Priority, m *Message) error {
+	if priority >= priorityLevels {
+		return fmt.Errorf("Invalid priority %d", priority)
+	}
+	return dev.queueContext(ctx, priority, m)
 }
 
 // //////////////////////////////////////////////////////////////////////////////////////
 // The following are the synchronous RF event functions used to update the synchronous data sent over a channel
 // //////////////////////////////////////////////////////////////////////////////////////
 
-func (dev *Ant) SendBroadcastData(channel uint8, data Packet) {
+// SendBroadcastData sets the data sent on the channel. A channel assigned receive only
+// (PARAMETER_RX_ONLY) returns ErrChannelNotTransmit and nothing is sent, the same goes for
+// the other send functions. Like WriteMessage they return ErrDeviceStopped when the device
+// isn't running.
+func (dev *Ant) SendBroadcastData(channel uint8, data Packet) error {
 	if len(data) != 8 {
 		panic(fmt.Sprint("Data length should be 8 not ", len(data)))
 	}
+	if err := dev.checkTransmit(channel); err != nil {
+		return err
+	}
 
 	payload := [9]byte{channel}
 	copy(payload[1:], data)
 	message := NewMessage(MESG_BROADCAST_DATA_ID, payload[:])
 
-	dev.write <- message
+	return dev.queueContext(context.Background(), PriorityData, message)
 }
 
-func (dev *Ant) SendAcknowledgedData(channel uint8, data Packet) {
+func (dev *Ant) SendAcknowledgedData(channel uint8, data Packet) error {
 	if len(data) != 8 {
 		panic(fmt.Sprint("Data length should be 8 not ", len(data)))
 	}
+	if err := dev.checkTransmit(channel); err != nil {
+		return err
+	}
+
 	payload := [9]byte{channel}
 	copy(payload[1:], data)
 	message := NewMessage(MESG_ACKNOWLEDGED_DATA_ID, payload[:])
-	dev.writeInTimeslot <- message
+	return dev.queueContext(context.Background(), PriorityData, message)
 }
 
-func (dev *Ant) SendBurstTransferPacket(channelSeq uint8, data Packet) {
+// SendAcknowledgedDataWithin sends acknowledged data, resending it each time the module reports
+// EVENT_TRANSFER_TX_FAILED, until the device acknowledges it or maxPeriods channel periods have
+// passed, in which case it returns an error wrapping ErrAckNotConfirmed. The period is the one
+// set on the channel, or the module default of 8192 (4Hz). It panics if maxPeriods is less than 1.
+func (dev *Ant) SendAcknowledgedDataWithin(ctx context.Context, channel uint8, data []byte, maxPeriods int) error {
+	if len(data) != 8 {
+		panic(fmt.Sprint("Data length should be 8 not ", len(data)))
+	}
+	if maxPeriods < 1 {
+		panic(fmt.Sprint("Periods should be at least 1 not ", maxPeriods))
+	}
+	if err := dev.checkTransmit(channel); err != nil {
+		return err
+	}
+
+	dev.mu.Lock()
+	period := dev.channelPeriod(channel)
+	dev.mu.Unlock()
+
+	results := make(chan bool, 1)
+	defer dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if !e.IsRFEvent() || (e.Code != EVENT_TRANSFER_TX_COMPLETED && e.Code != EVENT_TRANSFER_TX_FAILED) {
+			return
+		}
+		select {
+		case results <- e.Code == EVENT_TRANSFER_TX_COMPLETED:
+		default:
+		}
+	})()
+
+	timer := time.NewTimer(time.Duration(maxPeriods) * period)
+	defer timer.Stop()
+
+	payload := [9]byte{channel}
+	copy(payload[1:], data)
+	message := NewMessage(MESG_ACKNOWLEDGED_DATA_ID, payload[:])
+
+	for attempt := 1; ; attempt++ {
+		if err := dev.queueContext(ctx, PriorityData, message); err != nil {
+			return err
+		}
+
+		select {
+		case acknowledged := <-results:
+			if acknowledged {
+				return nil
+			}
+		case <-timer.C:
+			return fmt.Errorf("%w: %d attempts in %d periods", ErrAckNotConfirmed, attempt, maxPeriods)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SendAcknowledgedDataAwaitReply sends acknowledged data and waits for the reply of the device:
+// the next broadcast or acknowledged data received on the channel whose page, the first payload
+// byte, is matchPage, e.g. the calibration response to a calibration request. The reply handler is
+// registered before sending, so a fast reply isn't missed. It returns the error of ctx if it's done first.
+func (dev *Ant) SendAcknowledgedDataAwaitReply(ctx context.Context, channel uint8, data []byte, matchPage uint8) (*Message, error) {
+	if len(data) != 8 {
+		panic(fmt.Sprint("Data length should be 8 not ", len(data)))
+	}
+	if err := dev.checkTransmit(channel); err != nil {
+		return nil, err
+	}
+
+	replies := make(chan *Message, 1)
+	match := func(msg *Message) {
+		ch, payload, ok := msg.AsBroadcastData()
+		if !ok {
+			ch, payload, ok = msg.AsAcknowledgedData()
+		}
+		if !ok || ch != channel || payload[0] != matchPage {
+			return
+		}
+
+		select {
+		case replies <- msg:
+		default:
+		}
+	}
+	for _, id := range []uint8{
+		MESG_BROADCAST_DATA_ID, MESG_ACKNOWLEDGED_DATA_ID, MESG_EXT_BROADCAST_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID,
+	} {
+		defer dev.OnMessage(id, match)()
+	}
+
+	payload := [9]byte{channel}
+	copy(payload[1:], data)
+	if err := dev.queueContext(ctx, PriorityData, NewMessage(MESG_ACKNOWLEDGED_DATA_ID, payload[:])); err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-replies:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (dev *Ant) SendBurstTransferPacket(channelSeq uint8, data Packet) error {
+	if err := dev.checkTransmit(channelSeq & CHANNEL_NUMBER_MASK); err != nil {
+		return err
+	}
+
+	return dev.queueContext(context.Background(), PriorityData, burstTransferPacket(channelSeq, data))
+}
+
+func burstTransferPacket(channelSeq uint8, data Packet) *Message {
 	if len(data) != 8 {
 		panic(fmt.Sprint("Data length should be 8 not ", len(data)))
 	}
 
 	payload := [9]byte{channelSeq}
 	copy(payload[1:], data)
-	message := NewMessage(MESG_BURST_DATA_ID, payload[:])
-	dev.writeInTimeslot <- message
+	return NewMessage(MESG_BURST_DATA_ID, payload[:])
 }
 
-func (dev *Ant) SendBurstTransfer(channel uint8, data Packet) {
-	if len(data)%8 != 0 {
-		panic("Data length should be multiple of 8 not ")
+// BurstProgress is called after each packet of a burst transfer has been queued, or received,
+// with the number of bytes transferred so far and the total size of the transfer, see
+// OnBurstWithProgress for received transfers.
+type BurstProgress func(transferred int, total int)
+
+// SendBurstTransfer sends data as a burst transfer on the channel. The data length must be a
+// non-zero multiple of 8, shorter data has to be padded by the caller, otherwise nothing is sent.
+func (dev *Ant) SendBurstTransfer(channel uint8, data Packet) error {
+	return dev.SendBurstTransferWithProgress(channel, data, nil)
+}
+
+// SendBurstTransferWithProgress is like SendBurstTransfer but reports progress through the
+// given callback, which may be nil. With WithBurstCoalescing the packets are queued at once,
+// so progress is only reported when the whole transfer has been queued.
+// If the module reports EVENT_TRANSFER_TX_FAILED while packets are still being queued, as on
+// long flow-controlled transfers, the rest are dropped and an error wrapping ErrBurstFailed
+// tells how many bytes were sent. A failure reported once everything was queued only shows
+// through OnChannelEvent.
+func (dev *Ant) SendBurstTransferWithProgress(channel uint8, data Packet, progress BurstProgress) error {
+	if len(data) == 0 || len(data)%8 != 0 {
+		return fmt.Errorf("Burst data length should be a non-zero multiple of 8 not %d", len(data))
+	}
+	if err := dev.checkTransmit(channel); err != nil {
+		return err
 	}
 
-	packets := uint8(len(data) / 8)
+	packets := len(data) / 8
+	messages := make([]*Message, 0, packets)
 
-	for i := uint8(0); i < packets; i++ {
-		sequence := ((i - 1) % 3) + 1
+	for i := 0; i < packets; i++ {
+		// The first packet has sequence 0, the rest cycle through 1, 2, 3
+		sequence := SEQUENCE_FIRST_MESSAGE
+		if i > 0 {
+			sequence = uint8((i-1)%3+1) * SEQUENCE_NUMBER_INC
+		}
 
-		if i == 0 {
-			sequence = 0
-		} else if i == packets-1 {
-			sequence |= 0b100
+		// The last packet is flagged too, even if it is also the first
+		if i == packets-1 {
+			sequence |= SEQUENCE_LAST_MESSAGE
 		}
 
-		channelSeq := channel | sequence<<5
+		channelSeq := channel&CHANNEL_NUMBER_MASK | sequence
 
-		dev.SendBurstTransferPacket(channelSeq, data[i*8:i*8+8])
+		messages = append(messages, burstTransferPacket(channelSeq, data[i*8:i*8+8]))
 	}
 
+	ctx := context.Background()
+
+	if dev.coalesceBursts {
+		if err := dev.queueBatchContext(ctx, PriorityData, messages); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(len(data), len(data))
+		}
+		return nil
+	}
+
+	failed := make(chan struct{}, 1)
+	defer dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if e.IsRFEvent() && e.Code == EVENT_TRANSFER_TX_FAILED {
+			select {
+			case failed <- struct{}{}:
+			default:
+			}
+		}
+	})()
+
+	for i, message := range messages {
+		select {
+		case <-failed:
+			return fmt.Errorf("%w: %d of %d bytes sent", ErrBurstFailed, i*8, len(data))
+		default:
+		}
+
+		if err := dev.queueContext(ctx, PriorityData, message); err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress((i+1)*8, len(data))
+		}
+	}
+
+	return nil
 }
 
 // //////////////////////////////////////////////////////////////////////////////////////
@@ -345,22 +1456,209 @@ func (dev *Ant) SendBurstTransfer(channel uint8, data Packet) {
 // //////////////////////////////////////////////////////////////////////////////////////
 
 func (dev *Ant) AddChannelID(channel uint8, deviceNum uint16, deviceType uint8, transmissionType uint8, index uint8) {
+	_ = dev.AddChannelIDContext(context.Background(), channel, deviceNum, deviceType, transmissionType, index)
+}
+
+// AddChannelIDContext is AddChannelID giving up when ctx is done.
+func (dev *Ant) AddChannelIDContext(ctx context.Context, channel uint8, deviceNum uint16, deviceType uint8, transmissionType uint8, index uint8) error {
 	payload := [6]byte{channel, 0, 0, deviceType, transmissionType, index}
-	binary.LittleEndian.PutUint16(payload[1:], uint16(deviceNum))
+	binary.LittleEndian.PutUint16(payload[1:], deviceNum)
 	message := NewMessage(MESG_ID_LIST_ADD_ID, payload[:])
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
 func (dev *Ant) ConfigList(channel uint8, listSize uint8, exclude uint8) {
+	_ = dev.ConfigListContext(context.Background(), channel, listSize, exclude)
+}
+
+// ConfigListContext is ConfigList giving up when ctx is done.
+func (dev *Ant) ConfigListContext(ctx context.Context, channel uint8, listSize uint8, exclude uint8) error {
 	message := NewMessage(MESG_ID_LIST_ADD_ID, Packet{channel, listSize, exclude})
-	dev.write <- message
+	return dev.queueContext(ctx, PriorityConfig, message)
 }
 
+// OpenRxScanMode opens channel 0 in continuous scan mode, only passing on packets from
+// synchronous channels, see OpenRxScanModeExt.
 func (dev *Ant) OpenRxScanMode() {
-	message := NewMessage(MESG_OPEN_RX_SCAN_ID, Packet{0, 1}) // [0-Channel, 1-Enable]
-	dev.write <- message
+	_ = dev.OpenRxScanModeContext(context.Background())
+}
+
+// OpenRxScanModeContext is OpenRxScanMode giving up when ctx is done.
+func (dev *Ant) OpenRxScanModeContext(ctx context.Context) error {
+	return dev.OpenRxScanModeExtContext(ctx, true)
+}
+
+// OpenRxScanModeExt opens channel 0 in continuous scan mode. With syncPacketsOnly the module
+// drops the packets of asynchronous transmitters, otherwise it passes on everything it hears.
+func (dev *Ant) OpenRxScanModeExt(syncPacketsOnly bool) {
+	_ = dev.OpenRxScanModeExtContext(context.Background(), syncPacketsOnly)
+}
+
+// OpenRxScanModeExtContext is OpenRxScanModeExt giving up when ctx is done.
+func (dev *Ant) OpenRxScanModeExtContext(ctx context.Context, syncPacketsOnly bool) error {
+	var syncOnly uint8
+	if syncPacketsOnly {
+		syncOnly = 1
+	}
+
+	message := NewMessage(MESG_OPEN_RX_SCAN_ID, Packet{0, syncOnly}) // [Filler, Synchronous channel packets only]
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// EnableExtendedMessagesLegacy turns the channel ID extension of received data messages on or off
+// using MESG_RX_EXT_MESGS_ENABLE_ID, the command understood by older modules.
+func (dev *Ant) EnableExtendedMessagesLegacy(enable bool) {
+	_ = dev.EnableExtendedMessagesLegacyContext(context.Background(), enable)
+}
+
+// EnableExtendedMessagesLegacyContext is EnableExtendedMessagesLegacy giving up when ctx is done.
+func (dev *Ant) EnableExtendedMessagesLegacyContext(ctx context.Context, enable bool) error {
+	var e uint8
+	if enable {
+		e = 1
+	}
+	message := NewMessage(MESG_RX_EXT_MESGS_ENABLE_ID, Packet{0, e})
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// SetLibConfig selects the extended data (ANT_LIB_CONFIG_MESG_OUT_INC_* flags) appended to received data messages.
+func (dev *Ant) SetLibConfig(config uint8) {
+	_ = dev.SetLibConfigContext(context.Background(), config)
+}
+
+// SetLibConfigContext is SetLibConfig giving up when ctx is done.
+func (dev *Ant) SetLibConfigContext(ctx context.Context, config uint8) error {
+	message := NewMessage(MESG_ANTLIB_CONFIG_ID, Packet{0, config})
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// EnableExtendedMessages turns the channel ID extension of received data messages on or off.
+// It uses SetLibConfig, and falls back to EnableExtendedMessagesLegacy if the module rejects it.
+// If the module reported capabilities without extended message support it returns ErrUnsupportedByDevice.
+// It waits up to responseTimeout for the module to answer, see EnableExtendedMessagesContext.
+func (dev *Ant) EnableExtendedMessages(enable bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), responseTimeout)
+	defer cancel()
+	return dev.EnableExtendedMessagesContext(ctx, enable)
+}
+
+// EnableExtendedMessagesContext is EnableExtendedMessages giving up when ctx is done, in which
+// case the error of ctx is returned and the legacy command isn't tried.
+func (dev *Ant) EnableExtendedMessagesContext(ctx context.Context, enable bool) error {
+	if c, ok := dev.Capabilities(); ok && c.AdvancedOptions2&CAPABILITIES_EXT_MESSAGE_ENABLED == 0 {
+		return ErrUnsupportedByDevice
+	}
+
+	var config uint8
+	if enable {
+		config = ANT_LIB_CONFIG_MESG_OUT_INC_DEVICE_ID
+	}
+
+	message := NewMessage(MESG_ANTLIB_CONFIG_ID, Packet{0, config})
+	err := dev.commandSync(ctx, PriorityConfig, 0, message)
+
+	var rejected ResponseError
+	if errors.As(err, &rejected) {
+		return dev.EnableExtendedMessagesLegacyContext(ctx, enable)
+	}
+	return err
 }
 
 // //////////////////////////////////////////////////////////////////////////////////////
 // The following functions are used with AP2 modules (not AP1 or AT3)
 // //////////////////////////////////////////////////////////////////////////////////////
+
+func (dev *Ant) ConfigEventBuffering(config uint8, sizeThreshold uint16, timeThreshold uint16) error {
+	return dev.ConfigEventBufferingContext(context.Background(), config, sizeThreshold, timeThreshold)
+}
+
+// ConfigEventBufferingContext is ConfigEventBuffering giving up when ctx is done.
+func (dev *Ant) ConfigEventBufferingContext(ctx context.Context, config uint8, sizeThreshold uint16, timeThreshold uint16) error {
+	if err := dev.requireAdvancedOptions3(CAPABILITIES_EVENT_BUFFERING_ENABLED); err != nil {
+		return err
+	}
+
+	payload := [6]byte{0, config}
+	binary.LittleEndian.PutUint16(payload[2:], sizeThreshold)
+	binary.LittleEndian.PutUint16(payload[4:], timeThreshold)
+	message := NewMessage(MESG_EVENT_BUFFERING_CONFIG_ID, payload[:])
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// ConfigAdvancedBurst enables or disables advanced burst. The feature fields are 24-bit
+// ADV_BURST_CONFIG_* bitfields, the upper byte is ignored.
+func (dev *Ant) ConfigAdvancedBurst(enable bool, maxPacketLength uint8, requiredFeatures uint32, optionalFeatures uint32) error {
+	return dev.ConfigAdvancedBurstContext(context.Background(), enable, maxPacketLength, requiredFeatures, optionalFeatures)
+}
+
+// ConfigAdvancedBurstContext is ConfigAdvancedBurst giving up when ctx is done.
+func (dev *Ant) ConfigAdvancedBurstContext(ctx context.Context, enable bool, maxPacketLength uint8, requiredFeatures uint32, optionalFeatures uint32) error {
+	if err := dev.requireAdvancedOptions3(CAPABILITIES_ADVANCED_BURST_ENABLED); err != nil {
+		return err
+	}
+
+	payload := [9]byte{0, 0, maxPacketLength}
+	if enable {
+		payload[1] = 1
+	}
+	payload[3], payload[4], payload[5] = byte(requiredFeatures), byte(requiredFeatures>>8), byte(requiredFeatures>>16)
+	payload[6], payload[7], payload[8] = byte(optionalFeatures), byte(optionalFeatures>>8), byte(optionalFeatures>>16)
+	message := NewMessage(MESG_CONFIG_ADV_BURST_ID, payload[:])
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+func (dev *Ant) EnableEncryption(channel uint8, mode uint8, volatileKeyIndex uint8, decimationRate uint8) error {
+	return dev.EnableEncryptionContext(context.Background(), channel, mode, volatileKeyIndex, decimationRate)
+}
+
+// EnableEncryptionContext is EnableEncryption giving up when ctx is done.
+func (dev *Ant) EnableEncryptionContext(ctx context.Context, channel uint8, mode uint8, volatileKeyIndex uint8, decimationRate uint8) error {
+	if err := dev.requireAdvancedOptions3(CAPABILITIES_ENCRYPTED_CHANNEL_ENABLED); err != nil {
+		return err
+	}
+
+	message := NewMessage(MESG_ENCRYPT_ENABLE_ID, Packet{channel, mode, volatileKeyIndex, decimationRate})
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// ConfigAutoFrequency sets the three frequencies (offsets from 2400MHz) a channel assigned with
+// EXT_PARAM_FREQUENCY_AGILITY hops among when the current one gets congested.
+// The channel must have been assigned, otherwise ErrChannelNotAssigned is returned.
+func (dev *Ant) ConfigAutoFrequency(channel uint8, freq1 uint8, freq2 uint8, freq3 uint8) error {
+	return dev.ConfigAutoFrequencyContext(context.Background(), channel, freq1, freq2, freq3)
+}
+
+// ConfigAutoFrequencyContext is ConfigAutoFrequency giving up when ctx is done.
+func (dev *Ant) ConfigAutoFrequencyContext(ctx context.Context, channel uint8, freq1 uint8, freq2 uint8, freq3 uint8) error {
+	if err := dev.checkChannel(channel); err != nil {
+		return err
+	}
+
+	for _, freq := range [...]uint8{freq1, freq2, freq3} {
+		if int(freq) > RFFreqMaxMHz-RFFreqBaseMHz {
+			return fmt.Errorf("RF frequency offset should be at most %d not %d", RFFreqMaxMHz-RFFreqBaseMHz, freq)
+		}
+	}
+
+	message := NewMessage(MESG_AUTO_FREQ_CONFIG_ID, Packet{channel, freq1, freq2, freq3})
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// SetEventFilter stops the module from reporting the events set in mask, an OR of EVENT_FILTER_*
+// constants, on all channels, e.g. EVENT_FILTER_RX_FAIL on busy gateways. A zero mask reports every event.
+// Filtering EVENT_TX on a master channel stops OpenMasterChannel from refreshing its payload.
+func (dev *Ant) SetEventFilter(mask uint16) error {
+	return dev.SetEventFilterContext(context.Background(), mask)
+}
+
+// SetEventFilterContext is SetEventFilter giving up when ctx is done.
+func (dev *Ant) SetEventFilterContext(ctx context.Context, mask uint16) error {
+	if err := dev.requireAdvancedOptions3(CAPABILITIES_EVENT_FILTERING_ENABLED); err != nil {
+		return err
+	}
+
+	payload := [3]byte{0}
+	binary.LittleEndian.PutUint16(payload[1:], mask)
+	message := NewMessage(MESG_EVENT_FILTER_CONFIG_ID, payload[:])
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
@@ -0,0 +1,543 @@
+/*
+ * ant_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// testDriver is an in-memory Driver: Read returns the bytes fed to it and Write records the
+// frames written. With maxWrite set, each Write accepts at most that many bytes.
+type testDriver struct {
+	mu       sync.Mutex
+	in       Packet
+	out      Packet
+	maxWrite int
+}
+
+func (d *testDriver) Open() error { return nil }
+
+func (d *testDriver) Close() {}
+
+func (d *testDriver) BufferSize() int { return 64 }
+
+func (d *testDriver) Read(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := copy(b, d.in)
+	d.in = d.in[n:]
+	return n, nil
+}
+
+func (d *testDriver) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.maxWrite > 0 && len(b) > d.maxWrite {
+		b = b[:d.maxWrite]
+	}
+	d.out = append(d.out, b...)
+	return len(b), nil
+}
+
+func (d *testDriver) feed(messages ...*Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, m := range messages {
+		d.in = append(d.in, m.Encode()...)
+	}
+}
+
+// written decodes the frames written so far, failing the test on a corrupt one.
+func (d *testDriver) written(t *testing.T) (messages []*Message) {
+	t.Helper()
+
+	d.mu.Lock()
+	var decoder Decoder
+	_, _ = decoder.Write(d.out)
+	d.mu.Unlock()
+
+	for {
+		msg, err := decoder.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil {
+			break
+		}
+		messages = append(messages, msg)
+	}
+	if decoder.Buffered() != 0 {
+		t.Fatalf("%d bytes written after the last complete frame", decoder.Buffered())
+	}
+	return messages
+}
+
+// waitWritten waits for a message with the given ID to have been written and returns it.
+func (d *testDriver) waitWritten(t *testing.T, id uint8) *Message {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		for _, msg := range d.written(t) {
+			if msg.Id == id {
+				return msg
+			}
+		}
+	}
+	t.Fatalf("message 0x%02X not written", id)
+	return nil
+}
+
+// startTestAnt starts a silent Ant on the driver, stopped when the test ends.
+func startTestAnt(t *testing.T, driver Driver, options ...Option) *Ant {
+	t.Helper()
+
+	dev := MakeAnt(driver, nil, append([]Option{WithSilent()}, options...)...)
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dev.Stop)
+	return dev
+}
+
+func TestSetChannelIdLittleEndian(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+
+	dev.SetChannelId(1, 0x1234, 120, 1)
+	dev.AddChannelID(1, 0x1234, 120, 1, 2)
+	// Stop returns once the loop has written everything it took
+	dev.Stop()
+
+	want := []Packet{
+		{1, 0x34, 0x12, 120, 1},
+		{1, 0x34, 0x12, 120, 1, 2},
+	}
+	got := drv.written(t)
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i, msg := range got {
+		if !bytes.Equal(msg.Data, want[i]) {
+			t.Errorf("message %d: got %s, want %s", i, msg.Data, want[i])
+		}
+	}
+
+	if id, ok := got[0].AsChannelID(); !ok || id.DeviceNumber != 0x1234 {
+		t.Errorf("got channel ID %+v, want device number 0x1234", id)
+	}
+}
+
+func TestPauseBeforeStart(t *testing.T) {
+	drv := &testDriver{}
+	drv.feed(NewMessage(MESG_STARTUP_MESG_ID, Packet{RESET_POR}))
+
+	dev := MakeAnt(drv, nil, WithSilent(), WithReadBuffer(1, DropNewest))
+	dev.Pause()
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Stop()
+
+	select {
+	case msg := <-dev.Messages():
+		t.Fatalf("read %s while paused", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	dev.Resume()
+	select {
+	case <-dev.Messages():
+	case <-time.After(time.Second):
+		t.Fatal("nothing read after Resume")
+	}
+}
+
+// readCountingDriver is a testDriver counting reads, whose reads fail with err if set.
+type readCountingDriver struct {
+	testDriver
+	reads int32
+	err   error
+}
+
+func (d *readCountingDriver) Read(b []byte) (int, error) {
+	atomic.AddInt32(&d.reads, 1)
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.testDriver.Read(b)
+}
+
+func TestPauseStopsPolling(t *testing.T) {
+	drv := &readCountingDriver{}
+	dev := startTestAnt(t, drv)
+
+	dev.Pause()
+	before := atomic.LoadInt32(&drv.reads)
+	time.Sleep(50 * time.Millisecond)
+	// One poll may have been due as the pause came in
+	if reads := atomic.LoadInt32(&drv.reads) - before; reads > 1 {
+		t.Errorf("driver read %d times while paused", reads)
+	}
+
+	dev.Resume()
+	before = atomic.LoadInt32(&drv.reads)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&drv.reads) == before {
+		t.Error("driver not read after Resume")
+	}
+}
+
+// timeoutError is a driver read timing out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "read timed out" }
+func (timeoutError) Timeout() bool { return true }
+
+func TestReadErrors(t *testing.T) {
+	failure := errors.New("device unplugged")
+	for _, tt := range []struct {
+		err      error
+		reported bool
+	}{
+		{failure, true},
+		{timeoutError{}, false},
+	} {
+		drv := &readCountingDriver{err: tt.err}
+		dev := startTestAnt(t, drv)
+
+		select {
+		case err := <-dev.Errors():
+			if !tt.reported || !errors.Is(err, tt.err) {
+				t.Errorf("%v: got error %v", tt.err, err)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if tt.reported {
+				t.Errorf("%v: not reported", tt.err)
+			}
+		}
+
+		// Reported once, not on every poll
+		select {
+		case err := <-dev.Errors():
+			t.Errorf("%v: reported again: %v", tt.err, err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		dev.Stop()
+	}
+}
+
+func TestDedupLegacyExtended(t *testing.T) {
+	// Legacy extended broadcasts carry the channel ID before the payload
+	broadcast := func(heartRate uint8) *Message {
+		return NewMessage(MESG_EXT_BROADCAST_DATA_ID, Packet{0, 0x34, 0x12, 120, 1, 4, 0, 0, 0, 0, 0, 0, heartRate})
+	}
+
+	dev := MakeAnt(&testDriver{}, nil, WithSilent(), WithDedup(0))
+	for i, tt := range []struct {
+		msg       *Message
+		duplicate bool
+	}{
+		{broadcast(60), false},
+		{broadcast(60), true},
+		{broadcast(61), false},
+		{NewMessage(MESG_BROADCAST_DATA_ID, Packet{0, 4, 0, 0, 0, 0, 0, 0, 61}), true},
+	} {
+		if got := dev.isDuplicate(tt.msg); got != tt.duplicate {
+			t.Errorf("message %d: got duplicate %v, want %v", i, got, tt.duplicate)
+		}
+	}
+}
+
+func TestBurstSequence(t *testing.T) {
+	// The first packet has sequence 0, the next ones 1, 2, 3 (0x20 apart) rolling over from
+	// 0x60 back to 0x20, and the last one has bit 0x80 set, for the channel in the low bits.
+	tests := []struct {
+		name     string
+		size     int
+		sequence []uint8
+	}{
+		{"single packet", 8, []uint8{0x81}},
+		{"24 bytes", 24, []uint8{0x01, 0x21, 0xC1}},
+		{"72 bytes", 72, []uint8{0x01, 0x21, 0x41, 0x61, 0x21, 0x41, 0x61, 0x21, 0xC1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drv := &testDriver{}
+			dev := startTestAnt(t, drv)
+
+			data := make(Packet, tt.size)
+			for i := range data {
+				data[i] = byte(i)
+			}
+			if err := dev.SendBurstTransfer(1, data); err != nil {
+				t.Fatal(err)
+			}
+			dev.Stop()
+
+			written := drv.written(t)
+			if len(written) != len(tt.sequence) {
+				t.Fatalf("got %d packets, want %d", len(written), len(tt.sequence))
+			}
+			for i, msg := range written {
+				if msg.Id != MESG_BURST_DATA_ID {
+					t.Fatalf("packet %d: got message 0x%02X", i, msg.Id)
+				}
+				if msg.Data[0] != tt.sequence[i] {
+					t.Errorf("packet %d: got sequence byte 0x%02X, want 0x%02X", i, msg.Data[0], tt.sequence[i])
+				}
+				if !bytes.Equal(msg.Data[1:], data[i*8:i*8+8]) {
+					t.Errorf("packet %d: got data %s", i, msg.Data[1:])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteWhenNotRunning(t *testing.T) {
+	data := Packet{1, 2, 3, 4, 5, 6, 7, 8}
+	writes := []struct {
+		name  string
+		write func(dev *Ant) error
+	}{
+		{"WriteMessage", func(dev *Ant) error { return dev.WriteMessage(MESG_SYSTEM_RESET_ID, Packet{0}) }},
+		{"SendBroadcastData", func(dev *Ant) error { return dev.SendBroadcastData(0, data) }},
+		{"SendAcknowledgedData", func(dev *Ant) error { return dev.SendAcknowledgedData(0, data) }},
+		{"SendBurstTransferPacket", func(dev *Ant) error {
+			return dev.SendBurstTransferPacket(SEQUENCE_FIRST_MESSAGE|SEQUENCE_LAST_MESSAGE, data)
+		}},
+		{"SendBurstTransfer", func(dev *Ant) error { return dev.SendBurstTransfer(0, data) }},
+	}
+	check := func(dev *Ant, when string) {
+		t.Helper()
+		for _, w := range writes {
+			if err := w.write(dev); err != ErrDeviceStopped {
+				t.Errorf("%s %s: got %v, want %v", w.name, when, err, ErrDeviceStopped)
+			}
+		}
+	}
+
+	dev := MakeAnt(&testDriver{}, nil, WithSilent())
+	check(dev, "before Start")
+
+	dev.Stop()
+	check(dev, "after Stop without Start")
+
+	dev = startTestAnt(t, &testDriver{})
+	dev.Stop()
+	check(dev, "after Stop")
+}
+
+func TestStopLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	sim := NewSimDriver(SimSensor{
+		ID:      ChannelID{DeviceNumber: 1, DeviceType: 120, TransmissionType: 1},
+		Payload: func() (payload [8]byte) { return payload },
+	})
+	// A full read channel holds the decode loop up on a Block policy
+	dev := MakeAnt(sim, nil, WithSilent(), WithReadBuffer(1, Block))
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetChannelId(0, 1, 120, 1)
+	dev.SetChannelPeriod(0, 1024)
+	dev.OpenChannel(0)
+	dev.Pause()
+	dev.Resume()
+	time.Sleep(20 * time.Millisecond)
+
+	// The loop, read loop and decode loop must all have exited once Stop returns
+	dev.Stop()
+	dev.Stop()
+}
+
+func TestBurstLengthErrors(t *testing.T) {
+	for _, size := range []int{0, 1, 7, 9, 15, 17} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			drv := &testDriver{}
+			dev := startTestAnt(t, drv)
+
+			err := dev.SendBurstTransfer(0, make(Packet, size))
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if !strings.Contains(err.Error(), " "+strconv.Itoa(size)) {
+				t.Errorf("error %q doesn't tell the length", err)
+			}
+
+			dev.Stop()
+			if written := drv.written(t); len(written) != 0 {
+				t.Errorf("%d packets sent", len(written))
+			}
+		})
+	}
+}
+
+func TestShortWrites(t *testing.T) {
+	drv := &testDriver{maxWrite: 1}
+	dev := startTestAnt(t, drv)
+
+	want := []*Message{
+		NewMessage(MESG_CHANNEL_ID_ID, Packet{1, 0x34, 0x12, 120, 1}),
+		NewMessage(MESG_CHANNEL_MESG_PERIOD_ID, Packet{1, 0x86, 0x1F}),
+		NewMessage(MESG_CHANNEL_RADIO_FREQ_ID, Packet{1, 57}),
+		NewMessage(MESG_REQUEST_ID, Packet{1, MESG_CHANNEL_STATUS_ID}),
+	}
+	dev.SetChannelId(1, 0x1234, 120, 1)
+	dev.SetChannelPeriod(1, 8070)
+	dev.SetChannelRFFreq(1, 57)
+	dev.RequestMessage(1, MESG_CHANNEL_STATUS_ID)
+	dev.Stop()
+
+	var wantBytes Packet
+	for _, m := range want {
+		wantBytes = append(wantBytes, m.Encode()...)
+	}
+	if !bytes.Equal(drv.out, wantBytes) {
+		t.Errorf("got % X, want % X", []byte(drv.out), []byte(wantBytes))
+	}
+	if written := drv.written(t); len(written) != len(want) {
+		t.Errorf("got %d messages, want %d", len(written), len(want))
+	}
+}
+
+func TestOnResetOnlyUnexpected(t *testing.T) {
+	sim := NewSimDriver()
+	dev := startTestAnt(t, sim)
+
+	startups := make(chan StartupReason, 4)
+	resets := make(chan StartupReason, 4)
+	// Registered first, so it has run by the time a startup message is seen
+	dev.OnReset(func(reason StartupReason) { resets <- reason })
+	dev.OnMessage(MESG_STARTUP_MESG_ID, func(msg *Message) {
+		reason, _ := msg.AsStartupReason()
+		startups <- reason
+	})
+
+	wait := func(c chan StartupReason, what string) StartupReason {
+		t.Helper()
+		select {
+		case reason := <-c:
+			return reason
+		case <-time.After(time.Second):
+			t.Fatalf("no %s", what)
+			return 0
+		}
+	}
+
+	// The simulated module answers right away, possibly before ResetSystem returns
+	for i := 0; i < 20; i++ {
+		dev.ResetSystem()
+		wait(startups, "startup message")
+	}
+	select {
+	case reason := <-resets:
+		t.Fatalf("reset sent reported as unexpected: %v", reason)
+	default:
+	}
+
+	sim.mu.Lock()
+	sim.reply(MESG_STARTUP_MESG_ID, Packet{RESET_WDT})
+	sim.mu.Unlock()
+	if reason := wait(resets, "unexpected reset"); reason != StartupReason(RESET_WDT) {
+		t.Errorf("got reason %v, want a watchdog reset", reason)
+	}
+}
+
+// libConfigDriver is a testDriver answering SetLibConfig with code, or not at all if silent.
+type libConfigDriver struct {
+	testDriver
+	code   uint8
+	silent bool
+}
+
+func (d *libConfigDriver) Write(b []byte) (int, error) {
+	n, err := d.testDriver.Write(b)
+
+	var decoder Decoder
+	_, _ = decoder.Write(b)
+	for msg, _ := decoder.Next(); msg != nil; msg, _ = decoder.Next() {
+		if msg.Id == MESG_ANTLIB_CONFIG_ID && !d.silent {
+			d.feed(NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, MESG_ANTLIB_CONFIG_ID, d.code}))
+		}
+	}
+	return n, err
+}
+
+func TestEnableExtendedMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver *libConfigDriver
+		err    error
+		legacy bool
+	}{
+		{"accepted", &libConfigDriver{code: RESPONSE_NO_ERROR}, nil, false},
+		{"rejected", &libConfigDriver{code: INVALID_MESSAGE}, nil, true},
+		{"unanswered", &libConfigDriver{silent: true}, context.DeadlineExceeded, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := startTestAnt(t, tt.driver)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if err := dev.EnableExtendedMessagesContext(ctx, true); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+
+			dev.mu.Lock()
+			handlers := len(dev.eventHandlers[0])
+			dev.mu.Unlock()
+			if handlers != 0 {
+				t.Errorf("%d channel event handlers left", handlers)
+			}
+
+			dev.Stop()
+			legacy := false
+			for _, msg := range tt.driver.written(t) {
+				legacy = legacy || msg.Id == MESG_RX_EXT_MESGS_ENABLE_ID
+			}
+			if legacy != tt.legacy {
+				t.Errorf("got legacy command sent %v, want %v", legacy, tt.legacy)
+			}
+		})
+	}
+}
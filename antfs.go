@@ -0,0 +1,124 @@
+/*
+ * antfs.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	ANTFSNetwork uint8  = 1    // Network number the ANT-FS key is set on by OpenANTFSHostChannel
+	ANTFSPeriod  uint16 = 8192 // 4Hz, the default beacon period
+	ANTFSRFFreq  uint8  = 50   // 2450MHz, the default beacon frequency
+)
+
+func ANTFSNetworkKey() [8]uint8 {
+	return [8]uint8{0xA8, 0xA4, 0x23, 0xB9, 0xF5, 0x5E, 0x63, 0xC1}
+}
+
+// OpenANTFSHostChannel sets the ANT-FS key on network ANTFSNetwork and opens a slave channel
+// searching for the beacon of an ANT-FS client, the first step of any ANT-FS session.
+// A zero deviceNum matches any client.
+func (dev *Ant) OpenANTFSHostChannel(channel uint8, deviceNum uint16) error {
+	if err := dev.SetNetworkKey(ANTFSNetwork, ANTFSNetworkKey()); err != nil {
+		return err
+	}
+	if err := dev.AssignChannel(channel, ChannelTypeBidirectionalSlave, ANTFSNetwork); err != nil {
+		return err
+	}
+	dev.SetChannelId(channel, deviceNum, 0, 0)
+	dev.SetChannelPeriod(channel, ANTFSPeriod)
+	dev.SetChannelRFFreq(channel, ANTFSRFFreq)
+	dev.OpenChannel(channel)
+
+	return nil
+}
+
+const (
+	ANTFSBeaconID uint8 = 0x43
+
+	// Beacon channel period codes
+	ANTFS_BEACON_PERIOD_0_5HZ   uint8 = 0x00
+	ANTFS_BEACON_PERIOD_1HZ     uint8 = 0x01
+	ANTFS_BEACON_PERIOD_2HZ     uint8 = 0x02
+	ANTFS_BEACON_PERIOD_4HZ     uint8 = 0x03
+	ANTFS_BEACON_PERIOD_8HZ     uint8 = 0x04
+	ANTFS_BEACON_PERIOD_MATCH   uint8 = 0x07 // Same as the established channel period
+	ANTFS_BEACON_PERIOD_MASK    uint8 = 0x07
+	ANTFS_BEACON_PAIRING_BIT    uint8 = 0x08
+	ANTFS_BEACON_UPLOAD_BIT     uint8 = 0x10
+	ANTFS_BEACON_DATA_AVAIL_BIT uint8 = 0x20
+	ANTFS_BEACON_STATE_MASK     uint8 = 0x0F
+
+	// Client device states
+	ANTFS_STATE_LINK           uint8 = 0x00
+	ANTFS_STATE_AUTHENTICATION uint8 = 0x01
+	ANTFS_STATE_TRANSPORT      uint8 = 0x02
+	ANTFS_STATE_BUSY           uint8 = 0x03
+
+	// Authentication types
+	ANTFS_AUTH_PASSTHROUGH     uint8 = 0x00
+	ANTFS_AUTH_NA              uint8 = 0x01
+	ANTFS_AUTH_PAIRING_ONLY    uint8 = 0x02
+	ANTFS_AUTH_PASSKEY_PAIRING uint8 = 0x03
+)
+
+// ANTFSBeacon is the beacon an ANT-FS client broadcasts, telling the host its state.
+type ANTFSBeacon struct {
+	ChannelPeriod      uint8 // ANTFS_BEACON_PERIOD_* code
+	PairingEnabled     bool
+	UploadEnabled      bool
+	DataAvailable      bool
+	State              uint8 // ANTFS_STATE_*
+	AuthenticationType uint8 // ANTFS_AUTH_*
+
+	// Device descriptor, sent in the link state
+	DeviceType     uint16
+	ManufacturerID uint16
+
+	// Serial number of the host the client is talking to, sent in the authentication and transport states
+	HostSerialNumber uint32
+}
+
+// ParseANTFSBeacon decodes the payload of an ANT-FS beacon.
+func ParseANTFSBeacon(data [8]byte) (b ANTFSBeacon, err error) {
+	if data[0] != ANTFSBeaconID {
+		return b, fmt.Errorf("Not an ANT-FS beacon, expected ID 0x%02X but got 0x%02X", ANTFSBeaconID, data[0])
+	}
+
+	b.ChannelPeriod = data[1] & ANTFS_BEACON_PERIOD_MASK
+	b.PairingEnabled = data[1]&ANTFS_BEACON_PAIRING_BIT != 0
+	b.UploadEnabled = data[1]&ANTFS_BEACON_UPLOAD_BIT != 0
+	b.DataAvailable = data[1]&ANTFS_BEACON_DATA_AVAIL_BIT != 0
+	b.State = data[2] & ANTFS_BEACON_STATE_MASK
+	b.AuthenticationType = data[3]
+
+	if b.State == ANTFS_STATE_LINK {
+		b.DeviceType = binary.LittleEndian.Uint16(data[4:])
+		b.ManufacturerID = binary.LittleEndian.Uint16(data[6:])
+	} else {
+		b.HostSerialNumber = binary.LittleEndian.Uint32(data[4:])
+	}
+
+	return b, nil
+}
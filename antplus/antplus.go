@@ -0,0 +1,150 @@
+/*
+ * antplus.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package antplus holds what the ANT+ device profiles have in common: the common data pages
+// and a registry of page decoders, filled by the profile packages when they are imported.
+package antplus
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	PageNumberMask uint8 = 0x7F
+	PageToggleBit  uint8 = 0x80 // Flipped every 4 messages by the profiles that use it
+)
+
+var ErrUnknownPage = errors.New("No decoder for the data page")
+
+// PageDecoder decodes a data page into a profile specific value, e.g. an hrm.Data.
+type PageDecoder func(payload [8]byte) (interface{}, error)
+
+var registry = struct {
+	sync.RWMutex
+	pages    map[uint16]PageDecoder // Keyed by device type << 8 | page number
+	profiles map[uint8]PageDecoder  // Keyed by device type
+	unpaged  map[uint8]bool         // Device types without page numbers
+	toggle   map[uint8]bool         // Device types using the page toggle bit
+	common   map[uint8]PageDecoder  // Keyed by page number
+	user     map[uint16]PageDecoder // Keyed like pages, without masking the toggle bit
+}{
+	pages:    make(map[uint16]PageDecoder),
+	profiles: make(map[uint8]PageDecoder),
+	unpaged:  make(map[uint8]bool),
+	toggle:   make(map[uint8]bool),
+	common:   make(map[uint8]PageDecoder),
+	user:     make(map[uint16]PageDecoder),
+}
+
+// RegisterPage registers the decoder of a page of a device type, replacing any previous one.
+// Profile packages call it from init, so importing a profile package is enough to decode its pages.
+// Pages of profiles using the toggle bit are registered without it.
+func RegisterPage(deviceType uint8, page uint8, decoder PageDecoder) {
+	registry.Lock()
+	registry.pages[uint16(deviceType)<<8|uint16(page)] = decoder
+	registry.Unlock()
+}
+
+// RegisterToggleProfile tells that the profile of a device type uses the top bit of the page
+// number as a toggle bit (PageToggleBit), like the heart rate monitors do, so Decode ignores it.
+// The page numbers of other profiles are taken as sent.
+func RegisterToggleProfile(deviceType uint8) {
+	registry.Lock()
+	registry.toggle[deviceType] = true
+	registry.Unlock()
+}
+
+// RegisterProfile registers the decoder used for the pages of a device type that have no
+// decoder of their own. Profiles whose payload has no page number (e.g. the combined bike
+// speed & cadence sensor) are registered with paged false, their decoder then gets every payload.
+func RegisterProfile(deviceType uint8, paged bool, decoder PageDecoder) {
+	registry.Lock()
+	registry.profiles[deviceType] = decoder
+	registry.unpaged[deviceType] = !paged
+	registry.Unlock()
+}
+
+// Manufacturer specific pages. Profiles using the toggle bit only have 7 bit page numbers,
+// their manufacturer specific pages are the same range without the top bit (0x70 to 0x7F).
+const (
+	PageManufacturerSpecificFirst uint8 = 0xF0
+	PageManufacturerSpecificLast  uint8 = 0xFE
+)
+
+// RegisterPageDecoder registers a decoder for a page of a device type, typically a manufacturer
+// specific page or a page of a non-standard sensor, without forking the library. Decode consults
+// these decoders before the ones of the library. The page is matched as sent first, then, for
+// profiles using the toggle bit, without it, so their pages are best registered with it cleared.
+func RegisterPageDecoder(deviceType uint8, page uint8, fn func(payload [8]byte) interface{}) {
+	registry.Lock()
+	registry.user[uint16(deviceType)<<8|uint16(page)] = func(payload [8]byte) (interface{}, error) {
+		return fn(payload), nil
+	}
+	registry.Unlock()
+}
+
+// registerCommonPage registers the decoder of a page shared by all profiles.
+func registerCommonPage(page uint8, decoder PageDecoder) {
+	registry.Lock()
+	registry.common[page] = decoder
+	registry.Unlock()
+}
+
+// Decode decodes a broadcast payload of a device of the given type, using the decoder
+// registered for its page with RegisterPageDecoder, then the one of the library, then the
+// common pages, then the decoder of the whole profile.
+// The pairing bit of deviceType is ignored, and so is the toggle bit of the page number for the
+// profiles registered with RegisterToggleProfile, but by decoders registered for the page as sent.
+func Decode(deviceType uint8, payload [8]byte) (interface{}, error) {
+	deviceType &= 0x7F
+
+	registry.RLock()
+	page := payload[0]
+	if registry.toggle[deviceType] {
+		page &= PageNumberMask
+	}
+
+	var decoder PageDecoder
+	ok := false
+	if !registry.unpaged[deviceType] {
+		key := uint16(deviceType) << 8
+		decoder, ok = registry.user[key|uint16(payload[0])]
+		if !ok {
+			decoder, ok = registry.user[key|uint16(page)]
+		}
+		if !ok {
+			decoder, ok = registry.pages[key|uint16(page)]
+		}
+		if !ok {
+			decoder, ok = registry.common[page]
+		}
+	}
+	if !ok {
+		decoder, ok = registry.profiles[deviceType]
+	}
+	registry.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownPage
+	}
+	return decoder(payload)
+}
@@ -0,0 +1,89 @@
+/*
+ * antplus_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package antplus
+
+import "testing"
+
+func TestDecodeToggleBit(t *testing.T) {
+	const (
+		toggleType uint8 = 100
+		plainType  uint8 = 101
+	)
+
+	RegisterToggleProfile(toggleType)
+	RegisterPage(toggleType, 0x01, func(payload [8]byte) (interface{}, error) { return "toggle page 1", nil })
+	RegisterPage(plainType, 0x01, func(payload [8]byte) (interface{}, error) { return "plain page 1", nil })
+	RegisterPage(plainType, 0x81, func(payload [8]byte) (interface{}, error) { return "plain page 0x81", nil })
+
+	tests := []struct {
+		deviceType uint8
+		page       uint8
+		want       interface{}
+	}{
+		{toggleType, 0x01, "toggle page 1"},
+		{toggleType, 0x81, "toggle page 1"},
+		{plainType, 0x01, "plain page 1"},
+		{plainType, 0x81, "plain page 0x81"},
+		{plainType, 0x82, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Decode(tt.deviceType, [8]byte{tt.page})
+		if tt.want == nil {
+			if err != ErrUnknownPage {
+				t.Errorf("device type %d page 0x%02X: got %v, %v, want ErrUnknownPage", tt.deviceType, tt.page, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("device type %d page 0x%02X: got %v, %v, want %v", tt.deviceType, tt.page, got, err, tt.want)
+		}
+	}
+}
+
+func TestUint24LE(t *testing.T) {
+	tests := []struct {
+		value uint32
+		bytes [3]byte
+		read  uint32 // What reads back, the bits above 24 being dropped
+	}{
+		{0, [3]byte{0x00, 0x00, 0x00}, 0},
+		{0x123456, [3]byte{0x56, 0x34, 0x12}, 0x123456},
+		{0xFFFFFF, [3]byte{0xFF, 0xFF, 0xFF}, 0xFFFFFF},
+		{0x1000000, [3]byte{0x00, 0x00, 0x00}, 0},
+		{0xAB123456, [3]byte{0x56, 0x34, 0x12}, 0x123456},
+	}
+
+	for _, tt := range tests {
+		// Written past the start of a page, the bytes around the field are left alone
+		payload := [8]byte{0xEE, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE, 0xEE}
+		WriteUint24LE(payload[3:], tt.value)
+
+		want := [8]byte{0xEE, 0xEE, 0xEE, tt.bytes[0], tt.bytes[1], tt.bytes[2], 0xEE, 0xEE}
+		if payload != want {
+			t.Errorf("WriteUint24LE(0x%X): got % X, want % X", tt.value, payload, want)
+		}
+		if got := ReadUint24LE(payload[3:]); got != tt.read {
+			t.Errorf("ReadUint24LE after writing 0x%X: got 0x%X, want 0x%X", tt.value, got, tt.read)
+		}
+	}
+}
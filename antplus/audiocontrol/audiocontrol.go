@@ -0,0 +1,172 @@
+/*
+ * audiocontrol.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package audiocontrol implements the audio pages of the ANT+ Controls profile: the audio update
+// data broadcast by the controlled device (e.g. a media player) and the commands sent to it by a
+// remote as acknowledged data.
+package audiocontrol
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceType uint8  = 16   // Controls
+	Period     uint16 = 8192 // 4Hz
+	RFFreq     uint8  = 57
+
+	PageAudioUpdate  uint8 = 0x01
+	PageAudioCommand uint8 = 0x10
+
+	VolumeUnknown uint8  = 0xFF
+	TimeUnknown   uint16 = 0xFFFF
+)
+
+// State is the playback state of the audio device.
+type State uint8
+
+const (
+	StateOff     State = 0x00
+	StatePlay    State = 0x01
+	StatePause   State = 0x02
+	StateStop    State = 0x03
+	StateBusy    State = 0x04
+	StateUnknown State = 0x0F
+)
+
+// Repeat is the repeat mode of the audio device.
+type Repeat uint8
+
+const (
+	RepeatOff          Repeat = 0x00
+	RepeatCurrentTrack Repeat = 0x01
+	RepeatAllSongs     Repeat = 0x02
+	RepeatCustom       Repeat = 0x03
+)
+
+// Shuffle is the shuffle mode of the audio device.
+type Shuffle uint8
+
+const (
+	ShuffleOff    Shuffle = 0x00
+	ShuffleTracks Shuffle = 0x01
+	ShuffleAlbums Shuffle = 0x02
+	ShuffleCustom Shuffle = 0x03
+)
+
+// Command is an audio command number.
+type Command uint8
+
+const (
+	CommandPlay               Command = 1
+	CommandPause              Command = 2
+	CommandStop               Command = 3
+	CommandVolumeUp           Command = 4
+	CommandVolumeDown         Command = 5
+	CommandMute               Command = 6 // Mute or unmute
+	CommandAhead              Command = 7 // Next track
+	CommandBack               Command = 8 // Previous track
+	CommandRepeatCurrentTrack Command = 9
+	CommandRepeatAll          Command = 10
+	CommandRepeatOff          Command = 11
+	CommandShuffleSongs       Command = 12
+	CommandShuffleAlbums      Command = 13
+	CommandShuffleOff         Command = 14
+	CommandRepeatCustom       Command = 15
+	CommandShuffleCustom      Command = 16
+)
+
+func init() {
+	antplus.RegisterPage(DeviceType, PageAudioUpdate, func(payload [8]byte) (interface{}, error) {
+		return DecodeUpdate(payload)
+	})
+	antplus.RegisterPage(DeviceType, PageAudioCommand, func(payload [8]byte) (interface{}, error) {
+		return DecodeCommand(payload)
+	})
+}
+
+// Update is the audio update data page, broadcast by the audio device.
+type Update struct {
+	Volume           uint8  // Percent, VolumeUnknown if not known
+	TotalTrackTime   uint16 // Seconds, TimeUnknown if not known
+	CurrentTrackTime uint16 // Seconds, TimeUnknown if not known
+	State            State
+	Repeat           Repeat
+	Shuffle          Shuffle
+}
+
+// DecodeUpdate decodes the audio update data page.
+func DecodeUpdate(payload [8]byte) (u Update, err error) {
+	if payload[0] != PageAudioUpdate {
+		return u, fmt.Errorf("Not an audio update data page: 0x%02X", payload[0])
+	}
+
+	u.Volume = payload[1]
+	u.TotalTrackTime = binary.LittleEndian.Uint16(payload[2:])
+	u.CurrentTrackTime = binary.LittleEndian.Uint16(payload[4:])
+	u.State = State(payload[6] & 0x0F)
+	u.Repeat = Repeat(payload[6] >> 4 & 0x03)
+	u.Shuffle = Shuffle(payload[6] >> 6)
+	return u, nil
+}
+
+// Encode encodes the audio update data page, for an audio device.
+func (u Update) Encode() (payload [8]byte) {
+	payload[0] = PageAudioUpdate
+	payload[1] = u.Volume
+	binary.LittleEndian.PutUint16(payload[2:], u.TotalTrackTime)
+	binary.LittleEndian.PutUint16(payload[4:], u.CurrentTrackTime)
+	payload[6] = uint8(u.State)&0x0F | uint8(u.Repeat)&0x03<<4 | uint8(u.Shuffle)<<6
+	payload[7] = 0xFF
+	return payload
+}
+
+// CommandPage is the audio command page, sent by a remote as acknowledged data.
+type CommandPage struct {
+	SerialNumber uint16 // Of the remote, 0xFFFF if it has none
+	Command      Command
+}
+
+// DecodeCommand decodes the audio command page.
+func DecodeCommand(payload [8]byte) (c CommandPage, err error) {
+	if payload[0] != PageAudioCommand {
+		return c, fmt.Errorf("Not an audio command page: 0x%02X", payload[0])
+	}
+
+	c.SerialNumber = binary.LittleEndian.Uint16(payload[1:])
+	c.Command = Command(payload[7])
+	return c, nil
+}
+
+// Encode encodes the audio command page.
+func (c CommandPage) Encode() (payload [8]byte) {
+	payload = [8]byte{PageAudioCommand, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, uint8(c.Command)}
+	binary.LittleEndian.PutUint16(payload[1:], c.SerialNumber)
+	return payload
+}
+
+// EncodeCommand returns the command page a remote with the given serial number sends for command.
+func EncodeCommand(serialNumber uint16, command Command) [8]byte {
+	return CommandPage{SerialNumber: serialNumber, Command: command}.Encode()
+}
@@ -0,0 +1,139 @@
+/*
+ * bikespeedcadence.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package bikespeedcadence implements the ANT+ Bike Speed and Cadence profiles, covering
+// the combined speed & cadence sensor as well as the speed-only and cadence-only sensors.
+package bikespeedcadence
+
+import (
+	"encoding/binary"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceTypeSpeedCadence uint8 = 121
+	DeviceTypeCadence      uint8 = 122
+	DeviceTypeSpeed        uint8 = 123
+
+	PeriodSpeedCadence uint16 = 8086 // ~4.05Hz
+	PeriodCadence      uint16 = 8102 // ~4.04Hz
+	PeriodSpeed        uint16 = 8118 // ~4.03Hz
+
+	RFFreq uint8 = 57
+
+	EventTimeClock = 1024 // Event times are in 1/1024s
+)
+
+func init() {
+	antplus.RegisterProfile(DeviceTypeSpeedCadence, false, func(payload [8]byte) (interface{}, error) {
+		c, s := DecodeSpeedCadence(payload)
+		return SpeedCadenceData{c, s}, nil
+	})
+	antplus.RegisterToggleProfile(DeviceTypeCadence)
+	antplus.RegisterToggleProfile(DeviceTypeSpeed)
+	antplus.RegisterProfile(DeviceTypeCadence, true, func(payload [8]byte) (interface{}, error) {
+		return DecodeCadence(payload), nil
+	})
+	antplus.RegisterProfile(DeviceTypeSpeed, true, func(payload [8]byte) (interface{}, error) {
+		return DecodeSpeed(payload), nil
+	})
+}
+
+// SpeedData is the wheel part of a payload: the time of the last wheel revolution event
+// and the cumulative wheel revolutions. Both roll over at 65536.
+type SpeedData struct {
+	EventTime       uint16 // 1/1024s
+	RevolutionCount uint16
+}
+
+// CadenceData is the crank part of a payload: the time of the last crank revolution event
+// and the cumulative crank revolutions. Both roll over at 65536.
+type CadenceData struct {
+	EventTime       uint16 // 1/1024s
+	RevolutionCount uint16
+}
+
+// SpeedCadenceData is a decoded payload of a combined speed & cadence sensor,
+// as returned by antplus.Decode.
+type SpeedCadenceData struct {
+	Cadence CadenceData
+	Speed   SpeedData
+}
+
+// DecodeSpeedCadence decodes the payload of a combined speed & cadence sensor (device type 121).
+func DecodeSpeedCadence(payload [8]byte) (c CadenceData, s SpeedData) {
+	c.EventTime = binary.LittleEndian.Uint16(payload[0:])
+	c.RevolutionCount = binary.LittleEndian.Uint16(payload[2:])
+	s.EventTime = binary.LittleEndian.Uint16(payload[4:])
+	s.RevolutionCount = binary.LittleEndian.Uint16(payload[6:])
+	return c, s
+}
+
+// EncodeSpeedCadence encodes the payload of a combined speed & cadence sensor (device type 121).
+func EncodeSpeedCadence(c CadenceData, s SpeedData) (payload [8]byte) {
+	binary.LittleEndian.PutUint16(payload[0:], c.EventTime)
+	binary.LittleEndian.PutUint16(payload[2:], c.RevolutionCount)
+	binary.LittleEndian.PutUint16(payload[4:], s.EventTime)
+	binary.LittleEndian.PutUint16(payload[6:], s.RevolutionCount)
+	return payload
+}
+
+// DecodeSpeed decodes the revolution fields of a speed-only sensor (device type 123) payload.
+func DecodeSpeed(payload [8]byte) SpeedData {
+	return SpeedData{
+		EventTime:       binary.LittleEndian.Uint16(payload[4:]),
+		RevolutionCount: binary.LittleEndian.Uint16(payload[6:]),
+	}
+}
+
+// DecodeCadence decodes the revolution fields of a cadence-only sensor (device type 122) payload.
+func DecodeCadence(payload [8]byte) CadenceData {
+	return CadenceData{
+		EventTime:       binary.LittleEndian.Uint16(payload[4:]),
+		RevolutionCount: binary.LittleEndian.Uint16(payload[6:]),
+	}
+}
+
+// encodePaged encodes the default page (0) of a speed-only or cadence-only sensor.
+func encodePaged(toggle bool, eventTime uint16, revolutions uint16) (payload [8]byte) {
+	if toggle {
+		payload[0] = antplus.PageToggleBit
+	}
+	payload[1], payload[2], payload[3] = 0xFF, 0xFF, 0xFF // Reserved
+	binary.LittleEndian.PutUint16(payload[4:], eventTime)
+	binary.LittleEndian.PutUint16(payload[6:], revolutions)
+	return payload
+}
+
+// Speed computes the speed in m/s between two consecutive readings, handling the rollover of
+// both counters. ok is false when there was no new wheel revolution event between them,
+// i.e. the wheel has stopped or the reading was a repeat, in which case no speed can be derived.
+func Speed(prev, cur SpeedData, wheelCircumferenceMeters float64) (mps float64, ok bool) {
+	elapsed := cur.EventTime - prev.EventTime
+	revolutions := cur.RevolutionCount - prev.RevolutionCount
+
+	if elapsed == 0 || revolutions == 0 {
+		return 0, false
+	}
+
+	return float64(revolutions) * wheelCircumferenceMeters * EventTimeClock / float64(elapsed), true
+}
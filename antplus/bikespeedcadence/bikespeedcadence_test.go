@@ -0,0 +1,118 @@
+/*
+ * bikespeedcadence_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package bikespeedcadence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+func TestSpeed(t *testing.T) {
+	const circumference = 2.0
+
+	tests := []struct {
+		name      string
+		prev, cur SpeedData
+		mps       float64
+		ok        bool
+	}{
+		{"steady", SpeedData{1024, 10}, SpeedData{2048, 12}, 4, true},
+		{"event time rollover", SpeedData{65024, 10}, SpeedData{512, 12}, 4, true},
+		{"count rollover", SpeedData{1024, 65535}, SpeedData{2048, 1}, 4, true},
+		{"both roll over", SpeedData{65024, 65535}, SpeedData{512, 1}, 4, true},
+		{"repeat", SpeedData{1024, 10}, SpeedData{1024, 10}, 0, false},
+		{"no new revolution", SpeedData{1024, 10}, SpeedData{2048, 10}, 0, false},
+		{"no new event time", SpeedData{1024, 10}, SpeedData{1024, 12}, 0, false},
+	}
+
+	for _, tt := range tests {
+		mps, ok := Speed(tt.prev, tt.cur, circumference)
+		if mps != tt.mps || ok != tt.ok {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", tt.name, mps, ok, tt.mps, tt.ok)
+		}
+	}
+}
+
+func TestEncoderRollover(t *testing.T) {
+	tests := []struct {
+		name        string
+		revolutions uint32
+		lastEvent   time.Duration
+		want        SpeedData
+	}{
+		{"start", 0, 0, SpeedData{0, 0}},
+		{"before rollover", 65535, 63500 * time.Millisecond, SpeedData{65024, 65535}},
+		{"after rollover", 65537, 64500 * time.Millisecond, SpeedData{512, 1}},
+		{"second rollover", 2*65536 + 3, 129 * time.Second, SpeedData{1024, 3}},
+	}
+
+	for _, deviceType := range []uint8{DeviceTypeSpeedCadence, DeviceTypeSpeed, DeviceTypeCadence} {
+		e := Encoder{DeviceType: deviceType}
+		for _, tt := range tests {
+			e.Wheel(tt.revolutions, tt.lastEvent)
+			e.Crank(tt.revolutions, tt.lastEvent)
+			payload := e.Next()
+
+			var got SpeedData
+			switch deviceType {
+			case DeviceTypeSpeed:
+				got = DecodeSpeed(payload)
+			case DeviceTypeCadence:
+				got = SpeedData(DecodeCadence(payload))
+			default:
+				c, s := DecodeSpeedCadence(payload)
+				if c != CadenceData(s) {
+					t.Errorf("device type %d, %s: crank %+v differs from wheel %+v", deviceType, tt.name, c, s)
+				}
+				got = s
+			}
+			if got != tt.want {
+				t.Errorf("device type %d, %s: got %+v, want %+v", deviceType, tt.name, got, tt.want)
+			}
+		}
+	}
+
+	// Speed across the encoded rollover
+	e := Encoder{DeviceType: DeviceTypeSpeed}
+	e.Wheel(65535, 63500*time.Millisecond)
+	prev := DecodeSpeed(e.Next())
+	e.Wheel(65537, 64500*time.Millisecond)
+	cur := DecodeSpeed(e.Next())
+	if mps, ok := Speed(prev, cur, 2.0); !ok || mps != 4 {
+		t.Errorf("got speed (%v, %v) across rollover, want (4, true)", mps, ok)
+	}
+}
+
+func TestEncoderToggle(t *testing.T) {
+	e := Encoder{DeviceType: DeviceTypeCadence}
+	for message := 0; message < 3*togglePeriod*2; message++ {
+		payload := e.Next()
+		if page := payload[0] & antplus.PageNumberMask; page != 0 {
+			t.Fatalf("message %d: got page %d, want 0", message, page)
+		}
+		if toggle := (message/togglePeriod)%2 == 1; (payload[0]&antplus.PageToggleBit != 0) != toggle {
+			t.Fatalf("message %d: got 0x%02X, want toggle %v", message, payload[0], toggle)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * encoder.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package bikespeedcadence
+
+import "time"
+
+const togglePeriod = 4 // The page toggle bit of the separate sensors flips every 4 messages
+
+// Encoder produces the broadcast payloads of an emulated sensor. Feed it the cumulative
+// revolution counts and the time of the last revolution as they change, and call Next once
+// per channel period. DeviceType selects the payload format, the zero value meaning
+// DeviceTypeSpeedCadence; the separate sensors only send the default page.
+type Encoder struct {
+	DeviceType uint8
+
+	speed    SpeedData
+	cadence  CadenceData
+	messages int
+}
+
+// Wheel records the cumulative wheel revolutions and the time of the last one, measured from any fixed origin.
+func (e *Encoder) Wheel(revolutions uint32, lastEvent time.Duration) {
+	e.speed = SpeedData{EventTime: eventTime(lastEvent), RevolutionCount: uint16(revolutions)}
+}
+
+// Crank records the cumulative crank revolutions and the time of the last one, measured from any fixed origin.
+func (e *Encoder) Crank(revolutions uint32, lastEvent time.Duration) {
+	e.cadence = CadenceData{EventTime: eventTime(lastEvent), RevolutionCount: uint16(revolutions)}
+}
+
+// Next returns the payload for the next message.
+func (e *Encoder) Next() [8]byte {
+	toggle := (e.messages/togglePeriod)%2 == 1
+	e.messages++
+
+	switch e.DeviceType {
+	case DeviceTypeSpeed:
+		return encodePaged(toggle, e.speed.EventTime, e.speed.RevolutionCount)
+	case DeviceTypeCadence:
+		return encodePaged(toggle, e.cadence.EventTime, e.cadence.RevolutionCount)
+	default:
+		return EncodeSpeedCadence(e.cadence, e.speed)
+	}
+}
+
+// eventTime converts a duration to the 1/1024s event time, rolling over every 64s.
+func eventTime(t time.Duration) uint16 {
+	return uint16(t * EventTimeClock / time.Second)
+}
@@ -0,0 +1,151 @@
+/*
+ * common.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package antplus
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Common pages, which any profile can send among its background pages
+const (
+	PageRequest          uint8 = 0x46
+	PageManufacturerInfo uint8 = 0x50
+	PageProductInfo      uint8 = 0x51
+)
+
+// Command types of a data page request (page 70, 0x46)
+const (
+	CommandRequestDataPage          uint8 = 0x01
+	CommandRequestANTFSSession      uint8 = 0x02
+	CommandRequestDataPageFromSlave uint8 = 0x03
+	CommandRequestDataPageSet       uint8 = 0x04
+)
+
+// Requested transmissions of a data page request, besides a count of 1 to 127.
+const (
+	// RequestAcknowledged, or'ed into a count, asks the sensor to send the requested page as
+	// acknowledged data, so it is known to have arrived.
+	RequestAcknowledged uint8 = 0x80
+
+	// RequestUntilAcknowledged, with no count, asks the sensor to send the requested page as
+	// acknowledged data until it gets acknowledged.
+	RequestUntilAcknowledged uint8 = 0x80
+)
+
+var ErrNoTransmission = errors.New("Data page requested with no transmission")
+
+func init() {
+	registerCommonPage(PageRequest, func(payload [8]byte) (interface{}, error) {
+		return DecodeDataPageRequest(payload), nil
+	})
+	registerCommonPage(PageManufacturerInfo, func(payload [8]byte) (interface{}, error) {
+		return DecodeManufacturerInfo(payload), nil
+	})
+	registerCommonPage(PageProductInfo, func(payload [8]byte) (interface{}, error) {
+		return DecodeProductInfo(payload), nil
+	})
+}
+
+// ManufacturerInfo is the content of common page 80 (0x50).
+type ManufacturerInfo struct {
+	HardwareRevision uint8
+	ManufacturerID   uint16
+	ModelNumber      uint16
+}
+
+// DecodeManufacturerInfo decodes common page 80 (0x50).
+func DecodeManufacturerInfo(payload [8]byte) ManufacturerInfo {
+	return ManufacturerInfo{
+		HardwareRevision: payload[3],
+		ManufacturerID:   binary.LittleEndian.Uint16(payload[4:]),
+		ModelNumber:      binary.LittleEndian.Uint16(payload[6:]),
+	}
+}
+
+// ProductInfo is the content of common page 81 (0x51).
+type ProductInfo struct {
+	SoftwareRevisionSupplemental uint8 // 0xFF if not used
+	SoftwareRevisionMain         uint8
+	SerialNumber                 uint32 // 0xFFFFFFFF for devices without serial number
+}
+
+// DecodeProductInfo decodes common page 81 (0x51).
+func DecodeProductInfo(payload [8]byte) ProductInfo {
+	return ProductInfo{
+		SoftwareRevisionSupplemental: payload[2],
+		SoftwareRevisionMain:         payload[3],
+		SerialNumber:                 binary.LittleEndian.Uint32(payload[4:]),
+	}
+}
+
+// DataPageRequest is the content of common page 70 (0x46), sent as acknowledged data by a
+// display to have the sensor send a page it otherwise only sends rarely or not at all.
+type DataPageRequest struct {
+	SlaveSerialNumber uint16 // 0xFFFF if not used
+	Descriptor        [2]uint8
+	TxTimes           uint8 // how many times to send the page, with RequestAcknowledged if acknowledged, or RequestUntilAcknowledged
+	RequestedPage     uint8
+	CommandType       uint8 // e.g. CommandRequestDataPage
+}
+
+// DecodeDataPageRequest decodes common page 70 (0x46).
+func DecodeDataPageRequest(payload [8]byte) DataPageRequest {
+	return DataPageRequest{
+		SlaveSerialNumber: binary.LittleEndian.Uint16(payload[1:]),
+		Descriptor:        [2]uint8{payload[3], payload[4]},
+		TxTimes:           payload[5],
+		RequestedPage:     payload[6],
+		CommandType:       payload[7],
+	}
+}
+
+// Encode returns the page to send as acknowledged data.
+func (r DataPageRequest) Encode() (payload [8]byte) {
+	payload[0] = PageRequest
+	binary.LittleEndian.PutUint16(payload[1:], r.SlaveSerialNumber)
+	payload[3] = r.Descriptor[0]
+	payload[4] = r.Descriptor[1]
+	payload[5] = r.TxTimes
+	payload[6] = r.RequestedPage
+	payload[7] = r.CommandType
+	return payload
+}
+
+// RequestDataPage returns a data page request (page 70, 0x46) for requestedPage, to send as
+// acknowledged data, e.g. RequestDataPage(PageProductInfo, 4, CommandRequestDataPage) asks for
+// the product info four times. txTimes is how many times the sensor sends the page (1-127),
+// with RequestAcknowledged or'ed in for it to send it as acknowledged data, or
+// RequestUntilAcknowledged. A txTimes of 0 asks for no transmission and returns ErrNoTransmission.
+func RequestDataPage(requestedPage uint8, txTimes uint8, commandType uint8) ([8]byte, error) {
+	if txTimes == 0 {
+		return [8]byte{}, ErrNoTransmission
+	}
+
+	return DataPageRequest{
+		SlaveSerialNumber: 0xFFFF,
+		Descriptor:        [2]uint8{0xFF, 0xFF},
+		TxTimes:           txTimes,
+		RequestedPage:     requestedPage,
+		CommandType:       commandType,
+	}.Encode(), nil
+}
@@ -0,0 +1,59 @@
+/*
+ * common_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package antplus
+
+import "testing"
+
+func TestRequestDataPage(t *testing.T) {
+	tests := []struct {
+		txTimes uint8
+		err     error
+	}{
+		{0, ErrNoTransmission},
+		{1, nil},
+		{127, nil},
+		{4 | RequestAcknowledged, nil},
+		{RequestUntilAcknowledged, nil},
+	}
+
+	for _, tt := range tests {
+		payload, err := RequestDataPage(PageProductInfo, tt.txTimes, CommandRequestDataPage)
+		if err != tt.err {
+			t.Errorf("txTimes 0x%02X: got %v, want %v", tt.txTimes, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		want := DataPageRequest{
+			SlaveSerialNumber: 0xFFFF,
+			Descriptor:        [2]uint8{0xFF, 0xFF},
+			TxTimes:           tt.txTimes,
+			RequestedPage:     PageProductInfo,
+			CommandType:       CommandRequestDataPage,
+		}
+		if got := DecodeDataPageRequest(payload); payload[0] != PageRequest || got != want {
+			t.Errorf("txTimes 0x%02X: got %+v", tt.txTimes, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+/*
+ * endian.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package antplus
+
+// ANT+ multi-byte fields are little-endian: the least significant byte comes first in the
+// payload. 16 and 32-bit fields are read with encoding/binary's LittleEndian, 24-bit fields,
+// common for accumulated values (e.g. accumulated power or operating time), with ReadUint24LE.
+
+// ReadUint24LE reads a 24-bit little-endian field from the first 3 bytes of b.
+func ReadUint24LE(b []byte) uint32 {
+	_ = b[2] // Bounds check hint, as in encoding/binary
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+// WriteUint24LE writes v as a 24-bit little-endian field to the first 3 bytes of b.
+// The upper byte of v is dropped, matching the rollover of accumulated fields at 2^24.
+func WriteUint24LE(b []byte, v uint32) {
+	_ = b[2]
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,115 @@
+/*
+ * fec.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package fec implements data pages of the ANT+ Fitness Equipment Control (FE-C) profile.
+package fec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceType uint8  = 17
+	Period     uint16 = 8192 // 4Hz
+	RFFreq     uint8  = 57
+
+	// Control pages, sent to the trainer as acknowledged data
+	PageBasicResistance   uint8 = 0x30
+	PageTargetPower       uint8 = 0x31
+	PageWindResistance    uint8 = 0x32
+	PageTrackResistance   uint8 = 0x33
+	PageUserConfiguration uint8 = 0x37
+
+	PageCommandStatus uint8 = 0x47
+
+	TargetPowerResolution = 0.25 // W per unit of the target power
+)
+
+// CommandStatus is the status of the last control page received by the equipment.
+type CommandStatus uint8
+
+const (
+	StatusPass          CommandStatus = 0
+	StatusFail          CommandStatus = 1
+	StatusNotSupported  CommandStatus = 2
+	StatusRejected      CommandStatus = 3
+	StatusPending       CommandStatus = 4
+	StatusUninitialized CommandStatus = 0xFF // No command received yet
+)
+
+func init() {
+	antplus.RegisterPage(DeviceType, PageCommandStatus, func(payload [8]byte) (interface{}, error) {
+		return DecodeCommandStatus(payload)
+	})
+}
+
+// CommandStatusPage is the command status page (page 71, 0x47), which the equipment sends when
+// asked for it with a data page request (antplus.RequestDataPage), to confirm a control page.
+type CommandStatusPage struct {
+	LastCommand uint8 // Page number of the last control page received, 0xFF if none
+	Sequence    uint8 // Sequence number of the last control page received, 0xFF if none
+	Status      CommandStatus
+	Data        [4]byte // The last 4 bytes of the last control page, as applied
+}
+
+// DecodeCommandStatus decodes the command status page.
+func DecodeCommandStatus(payload [8]byte) (p CommandStatusPage, err error) {
+	if payload[0] != PageCommandStatus {
+		return p, fmt.Errorf("Not an FE-C command status page: 0x%02X", payload[0])
+	}
+
+	p.LastCommand = payload[1]
+	p.Sequence = payload[2]
+	p.Status = CommandStatus(payload[3])
+	copy(p.Data[:], payload[4:])
+	return p, nil
+}
+
+// Accepted tells if the last command was the given control page and the equipment applied it.
+func (p CommandStatusPage) Accepted(command uint8) bool {
+	return p.LastCommand == command && p.Status == StatusPass
+}
+
+// TargetPower returns the target power in W of a status confirming a target power page,
+// ok is false for other commands.
+func (p CommandStatusPage) TargetPower() (watts float64, ok bool) {
+	if p.LastCommand != PageTargetPower {
+		return 0, false
+	}
+	return float64(binary.LittleEndian.Uint16(p.Data[2:])) * TargetPowerResolution, true
+}
+
+// EncodeTargetPower encodes a target power page setting the trainer to watts, to send as
+// acknowledged data. It panics if watts is negative or above 16383.75W.
+func EncodeTargetPower(watts float64) (payload [8]byte) {
+	units := math.Round(watts / TargetPowerResolution)
+	if units < 0 || units > 0xFFFF {
+		panic(fmt.Sprint("Target power should be between 0W and 16383.75W not ", watts, "W"))
+	}
+
+	payload = [8]byte{PageTargetPower, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	binary.LittleEndian.PutUint16(payload[6:], uint16(units))
+	return payload
+}
@@ -0,0 +1,75 @@
+/*
+ * encoder.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package hrm
+
+const (
+	togglePeriod     = 4  // The page toggle bit flips every 4 messages
+	mainPageMessages = 64 // Messages of the main page between two background pages
+)
+
+var backgroundPages = [...]uint8{PageCumulativeOperatingTime, PageManufacturerInfo, PageProductInfo}
+
+// Encoder produces the broadcast payloads of an HRM master. It sends the main page
+// (PagePreviousHeartBeat) and, after every 64 main pages, one of the background pages
+// 1-3 in rotation, repeated for 4 messages. The background fields are taken from the encoder.
+type Encoder struct {
+	OperatingTime   uint32 // seconds
+	ManufacturerID  uint8
+	SerialNumber    uint16
+	HardwareVersion uint8
+	SoftwareVersion uint8
+	ModelNumber     uint8
+
+	messages   int
+	background int
+}
+
+// Next returns the payload for the next message, carrying the heart beat fields of beat.
+// It is meant to be called once per channel period, e.g. on every EVENT_TX.
+func (e *Encoder) Next(beat Data) [8]byte {
+	d := Data{
+		HeartBeatEventTime:         beat.HeartBeatEventTime,
+		HeartBeatCount:             beat.HeartBeatCount,
+		ComputedHeartRate:          beat.ComputedHeartRate,
+		PreviousHeartBeatEventTime: beat.PreviousHeartBeatEventTime,
+		OperatingTime:              e.OperatingTime,
+		ManufacturerID:             e.ManufacturerID,
+		SerialNumber:               e.SerialNumber,
+		HardwareVersion:            e.HardwareVersion,
+		SoftwareVersion:            e.SoftwareVersion,
+		ModelNumber:                e.ModelNumber,
+	}
+
+	d.Toggle = (e.messages/togglePeriod)%2 == 1
+
+	if cycle := e.messages % (mainPageMessages + togglePeriod); cycle < mainPageMessages {
+		d.Page = PagePreviousHeartBeat
+	} else {
+		d.Page = backgroundPages[e.background]
+		if cycle == mainPageMessages+togglePeriod-1 {
+			e.background = (e.background + 1) % len(backgroundPages)
+		}
+	}
+
+	e.messages++
+	return Encode(d)
+}
@@ -0,0 +1,72 @@
+/*
+ * encoder_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package hrm
+
+import (
+	"testing"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+func TestEncoderSchedule(t *testing.T) {
+	e := Encoder{OperatingTime: 3600, ManufacturerID: 1, SerialNumber: 0x1234, ModelNumber: 7}
+	beat := Data{HeartBeatEventTime: 0x2010, HeartBeatCount: 5, ComputedHeartRate: 72}
+
+	// 64 main pages then 4 of a background page, the background pages taking turns
+	want := func(message int) uint8 {
+		if cycle := message % 68; cycle < 64 {
+			return PagePreviousHeartBeat
+		}
+		return []uint8{PageCumulativeOperatingTime, PageManufacturerInfo, PageProductInfo}[(message/68)%3]
+	}
+
+	for message := 0; message < 3*68+10; message++ {
+		payload := e.Next(beat)
+		d := Decode(payload)
+
+		if d.Page != want(message) {
+			t.Fatalf("message %d: got page %d, want %d", message, d.Page, want(message))
+		}
+		if toggle := (message/4)%2 == 1; d.Toggle != toggle || (payload[0]&antplus.PageToggleBit != 0) != toggle {
+			t.Fatalf("message %d: got toggle %v (0x%02X), want %v", message, d.Toggle, payload[0], toggle)
+		}
+		if d.HeartBeatEventTime != 0x2010 || d.HeartBeatCount != 5 || d.ComputedHeartRate != 72 {
+			t.Fatalf("message %d: heart beat fields %+v", message, d)
+		}
+
+		switch d.Page {
+		case PageCumulativeOperatingTime:
+			// Sent in 2s units, which 3600s survives
+			if d.OperatingTime != 3600 {
+				t.Errorf("message %d: got operating time %d", message, d.OperatingTime)
+			}
+		case PageManufacturerInfo:
+			if d.ManufacturerID != 1 || d.SerialNumber != 0x1234 {
+				t.Errorf("message %d: got manufacturer info %+v", message, d)
+			}
+		case PageProductInfo:
+			if d.ModelNumber != 7 {
+				t.Errorf("message %d: got model number %d", message, d.ModelNumber)
+			}
+		}
+	}
+}
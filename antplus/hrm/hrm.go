@@ -0,0 +1,122 @@
+/*
+ * hrm.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package hrm implements the data pages of the ANT+ Heart Rate Monitor profile.
+package hrm
+
+import (
+	"encoding/binary"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceType uint8  = 120
+	Period     uint16 = 8070 // ~4.06Hz
+	RFFreq     uint8  = 57
+
+	PageDefault                 uint8 = 0x00
+	PageCumulativeOperatingTime uint8 = 0x01
+	PageManufacturerInfo        uint8 = 0x02
+	PageProductInfo             uint8 = 0x03
+	PagePreviousHeartBeat       uint8 = 0x04
+)
+
+func init() {
+	antplus.RegisterToggleProfile(DeviceType)
+	antplus.RegisterProfile(DeviceType, true, func(payload [8]byte) (interface{}, error) {
+		return Decode(payload), nil
+	})
+}
+
+// Data is the content of an HRM data page. The heart beat fields are present in every page,
+// the rest only in the page noted next to them.
+type Data struct {
+	Page   uint8
+	Toggle bool
+
+	HeartBeatEventTime uint16 // 1/1024s
+	HeartBeatCount     uint8
+	ComputedHeartRate  uint8 // bpm
+
+	OperatingTime              uint32 // Page 1, seconds with 2s resolution
+	ManufacturerID             uint8  // Page 2
+	SerialNumber               uint16 // Page 2, upper 16 bits of the serial number
+	HardwareVersion            uint8  // Page 3
+	SoftwareVersion            uint8  // Page 3
+	ModelNumber                uint8  // Page 3
+	PreviousHeartBeatEventTime uint16 // Page 4, 1/1024s
+}
+
+// Decode decodes an HRM broadcast payload.
+func Decode(payload [8]byte) (d Data) {
+	d.Page = payload[0] & antplus.PageNumberMask
+	d.Toggle = payload[0]&antplus.PageToggleBit != 0
+	d.HeartBeatEventTime = binary.LittleEndian.Uint16(payload[4:])
+	d.HeartBeatCount = payload[6]
+	d.ComputedHeartRate = payload[7]
+
+	switch d.Page {
+	case PageCumulativeOperatingTime:
+		d.OperatingTime = antplus.ReadUint24LE(payload[1:]) * 2
+	case PageManufacturerInfo:
+		d.ManufacturerID = payload[1]
+		d.SerialNumber = binary.LittleEndian.Uint16(payload[2:])
+	case PageProductInfo:
+		d.HardwareVersion = payload[1]
+		d.SoftwareVersion = payload[2]
+		d.ModelNumber = payload[3]
+	case PagePreviousHeartBeat:
+		d.PreviousHeartBeatEventTime = binary.LittleEndian.Uint16(payload[2:])
+	}
+
+	return d
+}
+
+// Encode encodes d as the data page d.Page. Page-specific fields of other pages are ignored.
+func Encode(d Data) (payload [8]byte) {
+	payload[0] = d.Page & antplus.PageNumberMask
+	if d.Toggle {
+		payload[0] |= antplus.PageToggleBit
+	}
+	binary.LittleEndian.PutUint16(payload[4:], d.HeartBeatEventTime)
+	payload[6] = d.HeartBeatCount
+	payload[7] = d.ComputedHeartRate
+
+	switch d.Page & antplus.PageNumberMask {
+	case PageCumulativeOperatingTime:
+		antplus.WriteUint24LE(payload[1:], d.OperatingTime/2)
+	case PageManufacturerInfo:
+		payload[1] = d.ManufacturerID
+		binary.LittleEndian.PutUint16(payload[2:], d.SerialNumber)
+	case PageProductInfo:
+		payload[1] = d.HardwareVersion
+		payload[2] = d.SoftwareVersion
+		payload[3] = d.ModelNumber
+	case PagePreviousHeartBeat:
+		payload[1] = 0xFF // Manufacturer specific
+		binary.LittleEndian.PutUint16(payload[2:], d.PreviousHeartBeatEventTime)
+	default:
+		payload[1], payload[2], payload[3] = 0xFF, 0xFF, 0xFF // Reserved
+	}
+
+	return payload
+}
@@ -0,0 +1,84 @@
+/*
+ * calibration.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package power
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Calibration IDs, the second byte of a calibration page
+const (
+	CalibrationRequest        uint8 = 0xAA // Manual zero offset calibration, display to sensor
+	CalibrationAutoZeroConfig uint8 = 0xAB // Display to sensor
+	CalibrationSuccess        uint8 = 0xAC // Sensor to display
+	CalibrationFailure        uint8 = 0xAF // Sensor to display
+)
+
+// Auto zero status of a calibration response
+const (
+	AutoZeroOff          uint8 = 0x00
+	AutoZeroOn           uint8 = 0x01
+	AutoZeroNotSupported uint8 = 0xFF
+)
+
+// CalibrationResponse is the sensor's answer to a calibration request.
+type CalibrationResponse struct {
+	Success        bool
+	AutoZeroStatus uint8 // AutoZeroOff, AutoZeroOn or AutoZeroNotSupported
+	Offset         int16 // Zero offset, in the sensor's raw units
+}
+
+// EncodeCalibrationRequest returns the payload asking the sensor for a manual zero offset
+// calibration, to be sent as acknowledged data. The sensor replies with a calibration response.
+func EncodeCalibrationRequest() [8]byte {
+	return [8]byte{PageCalibration, CalibrationRequest, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+}
+
+// EncodeAutoZeroConfig returns the payload turning the sensor's auto zero on or off,
+// to be sent as acknowledged data.
+func EncodeAutoZeroConfig(enable bool) [8]byte {
+	status := AutoZeroOff
+	if enable {
+		status = AutoZeroOn
+	}
+	return [8]byte{PageCalibration, CalibrationAutoZeroConfig, status, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+}
+
+// DecodeCalibrationResponse decodes a general calibration response page.
+func DecodeCalibrationResponse(payload [8]byte) (r CalibrationResponse, err error) {
+	if payload[0] != PageCalibration {
+		return r, fmt.Errorf("Not a calibration page: 0x%02X", payload[0])
+	}
+
+	switch payload[1] {
+	case CalibrationSuccess:
+		r.Success = true
+	case CalibrationFailure:
+	default:
+		return r, fmt.Errorf("Not a calibration response: 0x%02X", payload[1])
+	}
+
+	r.AutoZeroStatus = payload[2]
+	r.Offset = int16(binary.LittleEndian.Uint16(payload[6:]))
+	return r, nil
+}
@@ -0,0 +1,39 @@
+/*
+ * power.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package power implements the data pages of the ANT+ Bicycle Power profile.
+package power
+
+import "github.com/purpl3F0x/go-ant/antplus"
+
+const (
+	DeviceType uint8  = 11
+	Period     uint16 = 8182 // ~4.00Hz
+	RFFreq     uint8  = 57
+
+	PageCalibration uint8 = 0x01
+)
+
+func init() {
+	antplus.RegisterPage(DeviceType, PageCalibration, func(payload [8]byte) (interface{}, error) {
+		return DecodeCalibrationResponse(payload)
+	})
+}
@@ -0,0 +1,129 @@
+/*
+ * runningdynamics.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package runningdynamics implements the data pages of the ANT+ Running Dynamics profile,
+// sent by advanced foot pods and heart rate straps.
+package runningdynamics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceType uint8  = 30
+	Period     uint16 = 8192 // 4Hz
+	RFFreq     uint8  = 57
+
+	PageGroundContact uint8 = 0x01 // Ground contact time, vertical oscillation and stance time balance
+	PageStep          uint8 = 0x02 // Step length and vertical ratio
+)
+
+// Field resolutions
+const (
+	GroundContactTimeResolution   = time.Millisecond
+	VerticalOscillationResolution = 0.1  // mm
+	StanceTimeBalanceResolution   = 0.01 // %
+	VerticalRatioResolution       = 0.01 // %
+
+	invalid12 = 0xFFF
+	invalid16 = 0xFFFF
+)
+
+func init() {
+	decode := func(payload [8]byte) (interface{}, error) {
+		return Decode(payload)
+	}
+	antplus.RegisterPage(DeviceType, PageGroundContact, decode)
+	antplus.RegisterPage(DeviceType, PageStep, decode)
+}
+
+// Data is the content of a running dynamics page, only the fields of Page are set.
+// Fields the sensor marks invalid are left zero and reported by Valid.
+type Data struct {
+	Page uint8
+
+	GroundContactTime   time.Duration // Page 1
+	VerticalOscillation float64       // Page 1, mm
+	StanceTimeBalance   float64       // Page 1, % of the ground contact time spent on the left foot
+	StepLength          uint16        // Page 2, mm
+	VerticalRatio       float64       // Page 2, vertical oscillation to step length, %
+
+	// Valid has a bit set for each field above that the sensor reported, in their order
+	Valid uint8
+}
+
+const (
+	ValidGroundContactTime uint8 = 1 << iota
+	ValidVerticalOscillation
+	ValidStanceTimeBalance
+	ValidStepLength
+	ValidVerticalRatio
+)
+
+// Decode decodes a running dynamics page.
+//
+//	Page 1: bytes 1-2 low 12 bits  ground contact time, 1ms
+//	        bytes 2-3 high 12 bits vertical oscillation, 0.1mm
+//	        bytes 4-5              stance time balance, 0.01% left
+//	Page 2: bytes 1-2              step length, mm
+//	        bytes 3-4              vertical ratio, 0.01%
+//
+// Multi-byte fields are little-endian, all ones marks a field as invalid.
+func Decode(payload [8]byte) (d Data, err error) {
+	d.Page = payload[0]
+
+	switch d.Page {
+	case PageGroundContact:
+		packed := antplus.ReadUint24LE(payload[1:])
+
+		if gct := packed & 0xFFF; gct != invalid12 {
+			d.GroundContactTime = time.Duration(gct) * GroundContactTimeResolution
+			d.Valid |= ValidGroundContactTime
+		}
+		if vo := packed >> 12; vo != invalid12 {
+			d.VerticalOscillation = float64(vo) * VerticalOscillationResolution
+			d.Valid |= ValidVerticalOscillation
+		}
+		if balance := binary.LittleEndian.Uint16(payload[4:]); balance != invalid16 {
+			d.StanceTimeBalance = float64(balance) * StanceTimeBalanceResolution
+			d.Valid |= ValidStanceTimeBalance
+		}
+
+	case PageStep:
+		if length := binary.LittleEndian.Uint16(payload[1:]); length != invalid16 {
+			d.StepLength = length
+			d.Valid |= ValidStepLength
+		}
+		if ratio := binary.LittleEndian.Uint16(payload[3:]); ratio != invalid16 {
+			d.VerticalRatio = float64(ratio) * VerticalRatioResolution
+			d.Valid |= ValidVerticalRatio
+		}
+
+	default:
+		return d, fmt.Errorf("Not a running dynamics page: 0x%02X", d.Page)
+	}
+
+	return d, nil
+}
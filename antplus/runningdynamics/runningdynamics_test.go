@@ -0,0 +1,123 @@
+/*
+ * runningdynamics_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package runningdynamics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+// page returns a payload starting with b, the rest zero as from a shorter message.
+func page(b ...byte) (payload [8]byte) {
+	copy(payload[:], b)
+	return payload
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload [8]byte
+		want    Data
+	}{
+		{
+			name: "ground contact",
+			// 250ms and 95.3mm packed in 12 bits each: 0x0FA | 0x3B9<<12, 49.50% left
+			payload: [8]byte{0x01, 0xFA, 0x90, 0x3B, 0x56, 0x13, 0xFF, 0xFF},
+			want: Data{Page: PageGroundContact, GroundContactTime: 250 * time.Millisecond, VerticalOscillation: 95.3,
+				StanceTimeBalance: 49.5, Valid: ValidGroundContactTime | ValidVerticalOscillation | ValidStanceTimeBalance},
+		},
+		{
+			name:    "ground contact time invalid",
+			payload: [8]byte{0x01, 0xFF, 0x9F, 0x3B, 0xFF, 0xFF, 0xFF, 0xFF},
+			want:    Data{Page: PageGroundContact, VerticalOscillation: 95.3, Valid: ValidVerticalOscillation},
+		},
+		{
+			name:    "vertical oscillation invalid",
+			payload: [8]byte{0x01, 0xFA, 0xF0, 0xFF, 0x56, 0x13, 0xFF, 0xFF},
+			want: Data{Page: PageGroundContact, GroundContactTime: 250 * time.Millisecond, StanceTimeBalance: 49.5,
+				Valid: ValidGroundContactTime | ValidStanceTimeBalance},
+		},
+		{
+			name:    "largest packed values",
+			payload: [8]byte{0x01, 0xFE, 0xEF, 0xFF, 0x10, 0x27, 0xFF, 0xFF},
+			want: Data{Page: PageGroundContact, GroundContactTime: 4094 * time.Millisecond, VerticalOscillation: 409.4,
+				StanceTimeBalance: 100, Valid: ValidGroundContactTime | ValidVerticalOscillation | ValidStanceTimeBalance},
+		},
+		{
+			name:    "step",
+			payload: [8]byte{0x02, 0xB0, 0x04, 0x1A, 0x03, 0xFF, 0xFF, 0xFF},
+			want:    Data{Page: PageStep, StepLength: 1200, VerticalRatio: 7.94, Valid: ValidStepLength | ValidVerticalRatio},
+		},
+		{
+			name:    "step invalid",
+			payload: [8]byte{0x02, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			want:    Data{Page: PageStep},
+		},
+		{
+			name:    "short step page",
+			payload: page(0x02, 0xB0, 0x04),
+			want:    Data{Page: PageStep, StepLength: 1200, Valid: ValidStepLength | ValidVerticalRatio},
+		},
+		{
+			name:    "short ground contact page",
+			payload: page(0x01),
+			want:    Data{Page: PageGroundContact, Valid: ValidGroundContactTime | ValidVerticalOscillation | ValidStanceTimeBalance},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Decode(tt.payload)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Page != tt.want.Page || got.Valid != tt.want.Valid || got.GroundContactTime != tt.want.GroundContactTime ||
+			got.StepLength != tt.want.StepLength || !near(got.VerticalOscillation, tt.want.VerticalOscillation) ||
+			!near(got.StanceTimeBalance, tt.want.StanceTimeBalance) || !near(got.VerticalRatio, tt.want.VerticalRatio) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeUnknownPage(t *testing.T) {
+	// The profile has no toggle bit, 0x81 is not page 1
+	for _, p := range []uint8{0x00, 0x03, 0x81, 0x82} {
+		if d, err := Decode(page(p)); err == nil {
+			t.Errorf("page 0x%02X: got %+v, want an error", p, d)
+		}
+		if d, err := antplus.Decode(DeviceType, page(p)); err == nil {
+			t.Errorf("registry, page 0x%02X: got %+v, want an error", p, d)
+		}
+	}
+
+	d, err := antplus.Decode(DeviceType, page(PageStep, 0xB0, 0x04))
+	if data, ok := d.(Data); err != nil || !ok || data.StepLength != 1200 {
+		t.Errorf("registry: got %+v, %v", d, err)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
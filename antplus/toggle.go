@@ -0,0 +1,119 @@
+/*
+ * toggle.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package antplus
+
+import "sync"
+
+// toggleUnknownAfter is how many messages without the toggle bit flipping tell a device doesn't
+// use it: it flips every 4 messages, so it must have flipped within the first 5.
+const toggleUnknownAfter = 5
+
+// ToggleStatus is what a ToggleTracker knows about the device on a channel.
+type ToggleStatus struct {
+	Known    bool // Whether the device uses the toggle bit has been determined
+	Paged    bool // The toggle bit flips, so the first byte is a page number; false for legacy devices
+	Complete bool // Paged and all the background pages of the tracker have been received
+}
+
+// ToggleTracker follows the page toggle bit (PageToggleBit) of the devices on several channels,
+// to tell whether they send data pages and when all the background pages have been seen.
+// Until the toggle bit has flipped the first byte can't be trusted to be a page number: legacy
+// devices don't flip it and fill the first byte with something else. It is safe for concurrent use.
+type ToggleTracker struct {
+	background []uint8
+
+	mu       sync.Mutex
+	channels map[uint8]*toggleState
+}
+
+type toggleState struct {
+	toggle   bool
+	messages int
+	paged    bool
+	pages    map[uint8]bool
+}
+
+// NewToggleTracker returns a tracker reporting complete once the given background pages have
+// all been received, e.g. the HRM pages 1 to 3.
+func NewToggleTracker(background ...uint8) *ToggleTracker {
+	return &ToggleTracker{background: background, channels: make(map[uint8]*toggleState)}
+}
+
+// Add records a payload received on the channel and returns what is known of the device so far.
+func (t *ToggleTracker) Add(channel uint8, payload [8]byte) ToggleStatus {
+	toggle := payload[0]&PageToggleBit != 0
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.channels[channel]
+	if !ok {
+		s = &toggleState{toggle: toggle, pages: make(map[uint8]bool)}
+		t.channels[channel] = s
+	}
+
+	s.messages++
+	if toggle != s.toggle {
+		s.paged = true
+		s.toggle = toggle
+	}
+	s.pages[payload[0]&PageNumberMask] = true
+
+	return t.status(s)
+}
+
+// Status returns what is known of the device on the channel.
+func (t *ToggleTracker) Status(channel uint8) ToggleStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.channels[channel]
+	if !ok {
+		return ToggleStatus{}
+	}
+	return t.status(s)
+}
+
+// Reset forgets the device on the channel, e.g. when it is reopened for another device.
+func (t *ToggleTracker) Reset(channel uint8) {
+	t.mu.Lock()
+	delete(t.channels, channel)
+	t.mu.Unlock()
+}
+
+func (t *ToggleTracker) status(s *toggleState) ToggleStatus {
+	status := ToggleStatus{
+		Known: s.paged || s.messages >= toggleUnknownAfter,
+		Paged: s.paged,
+	}
+
+	if s.paged {
+		status.Complete = true
+		for _, page := range t.background {
+			if !s.pages[page&PageNumberMask] {
+				status.Complete = false
+				break
+			}
+		}
+	}
+	return status
+}
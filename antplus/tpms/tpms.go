@@ -0,0 +1,87 @@
+/*
+ * tpms.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+// Package tpms implements the data pages of the ANT+ Tire Pressure Monitoring profile.
+package tpms
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+const (
+	DeviceType uint8  = 48
+	Period     uint16 = 8192 // 4Hz
+	RFFreq     uint8  = 57
+
+	PageMain uint8 = 0x01
+
+	PressureResolutionKPa = 0.1 // kPa per unit of the raw pressure
+)
+
+// Alert flags, in the alerts byte of the main page
+const (
+	AlertOverPressure      uint8 = 0x01
+	AlertUnderPressure     uint8 = 0x02
+	AlertOverTemperature   uint8 = 0x04
+	AlertRapidPressureLoss uint8 = 0x08
+	AlertLowBattery        uint8 = 0x10
+	AlertSensorFault       uint8 = 0x20
+)
+
+func init() {
+	antplus.RegisterPage(DeviceType, PageMain, func(payload [8]byte) (interface{}, error) {
+		return Decode(payload)
+	})
+}
+
+// Data is the content of the main page.
+type Data struct {
+	Alerts      uint8  // OR of Alert* flags
+	RawPressure uint16 // In PressureResolutionKPa units, see Pressure
+	Temperature int8   // °C
+	Position    uint8  // Tire the sensor is mounted on, as numbered by the installer
+}
+
+// Pressure returns the tire pressure in kPa.
+func (d Data) Pressure() float64 {
+	return float64(d.RawPressure) * PressureResolutionKPa
+}
+
+// Has tells if the alert flag is set.
+func (d Data) Has(alert uint8) bool {
+	return d.Alerts&alert != 0
+}
+
+// Decode decodes the main page of a TPMS sensor.
+func Decode(payload [8]byte) (d Data, err error) {
+	if page := payload[0]; page != PageMain {
+		return d, fmt.Errorf("Not a TPMS main page: 0x%02X", page)
+	}
+
+	d.Alerts = payload[1]
+	d.RawPressure = binary.LittleEndian.Uint16(payload[2:])
+	d.Temperature = int8(payload[4])
+	d.Position = payload[5]
+	return d, nil
+}
@@ -0,0 +1,53 @@
+/*
+ * tpms_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package tpms
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	payload := [8]byte{PageMain, AlertUnderPressure | AlertLowBattery, 0xD0, 0x07, 0xFB, 2, 0xFF, 0xFF}
+
+	d, err := Decode(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Data{Alerts: AlertUnderPressure | AlertLowBattery, RawPressure: 2000, Temperature: -5, Position: 2}
+	if d != want {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+	if d.Pressure() != 200 {
+		t.Errorf("got pressure %v kPa, want 200", d.Pressure())
+	}
+	if !d.Has(AlertLowBattery) || d.Has(AlertSensorFault) {
+		t.Errorf("got alerts 0x%02X", d.Alerts)
+	}
+}
+
+func TestDecodeOtherPages(t *testing.T) {
+	// TPMS has no toggle bit, so a set high bit is a different page
+	for _, page := range []uint8{0x00, 0x02, PageMain | 0x80} {
+		if _, err := Decode([8]byte{page}); err == nil {
+			t.Errorf("page 0x%02X: decoded as the main page", page)
+		}
+	}
+}
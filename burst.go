@@ -0,0 +1,185 @@
+/*
+ * burst.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrBurstFailed is returned when a burst transfer is cut short. For received transfers it is
+// either reported by the module (EVENT_TRANSFER_RX_FAILED) or noticed from a gap in the sequence
+// numbers, for sent ones the module reported EVENT_TRANSFER_TX_FAILED.
+var ErrBurstFailed = errors.New("Burst transfer failed")
+
+// BurstReassembler puts the packets of received burst transfers back together.
+// The zero value is ready to use.
+type BurstReassembler struct {
+	data     Packet
+	sequence uint8 // Sequence expected next, meaningless while no transfer is in progress
+	started  bool
+}
+
+// Add adds a received burst packet. When it completes a transfer the whole transfer is returned
+// with done set. A packet out of sequence discards the transfer in progress and returns
+// ErrBurstFailed, the next transfer is assembled normally.
+func (r *BurstReassembler) Add(msg *Message) (data Packet, done bool, err error) {
+	if msg.Id != MESG_BURST_DATA_ID || len(msg.Data) < 1+int(ANT_STANDARD_DATA_PAYLOAD_SIZE) {
+		return nil, false, fmt.Errorf("Not a burst packet: %s", msg)
+	}
+
+	sequence := msg.Data[0] & SEQUENCE_NUMBER_MASK &^ SEQUENCE_LAST_MESSAGE
+	last := msg.Data[0]&SEQUENCE_LAST_MESSAGE != 0
+
+	switch {
+	case sequence == SEQUENCE_FIRST_MESSAGE:
+		if r.started {
+			// A new transfer began before the previous one ended
+			err = ErrBurstFailed
+		}
+		r.data = r.data[:0]
+		r.started = true
+
+	case !r.started:
+		// The start of the transfer was missed
+		return nil, false, ErrBurstFailed
+
+	case sequence != r.sequence:
+		expected := r.sequence
+		r.Reset()
+		return nil, false, fmt.Errorf("%w: expected sequence %d, got %d", ErrBurstFailed, expected>>5, sequence>>5)
+	}
+
+	r.data = append(r.data, msg.Data[1:1+ANT_STANDARD_DATA_PAYLOAD_SIZE]...)
+
+	// The sequence cycles through 1, 2, 3 after the first packet
+	r.sequence = sequence + SEQUENCE_NUMBER_INC
+	if r.sequence > SEQUENCE_NUMBER_ROLLOVER {
+		r.sequence = SEQUENCE_NUMBER_INC
+	}
+
+	if last {
+		data = append(Packet(nil), r.data...)
+		r.Reset()
+		return data, true, err
+	}
+	return nil, false, err
+}
+
+// Fail discards the transfer in progress, to be called on EVENT_TRANSFER_RX_FAILED.
+// It returns ErrBurstFailed if a transfer was in progress.
+func (r *BurstReassembler) Fail() error {
+	if !r.started {
+		return nil
+	}
+	r.Reset()
+	return ErrBurstFailed
+}
+
+// Len returns the number of bytes of the transfer in progress received so far.
+func (r *BurstReassembler) Len() int {
+	if !r.started {
+		return 0
+	}
+	return len(r.data)
+}
+
+// Reset discards the transfer in progress.
+func (r *BurstReassembler) Reset() {
+	r.data = r.data[:0]
+	r.started = false
+}
+
+// OnBurst registers fn to be called with every burst transfer received on the channel, or with
+// an error wrapping ErrBurstFailed when one fails, in which case the sender has to retry.
+// Like other handlers it is called from the decoding goroutine. The returned function unregisters it.
+func (dev *Ant) OnBurst(channel uint8, fn func(data Packet, err error)) (remove func()) {
+	return dev.OnBurstWithProgress(channel, fn, nil)
+}
+
+// OnBurstWithProgress is like OnBurst but also calls progress, which may be nil, after each packet
+// received in sequence. The size of a received transfer isn't known in advance, so total is 0
+// until the last packet, for which it is the size of the whole transfer.
+func (dev *Ant) OnBurstWithProgress(channel uint8, fn func(data Packet, err error), progress BurstProgress) (remove func()) {
+	var r BurstReassembler
+
+	removeMessage := dev.OnMessage(MESG_BURST_DATA_ID, func(msg *Message) {
+		if msg.Channel() != channel {
+			return
+		}
+
+		data, done, err := r.Add(msg)
+		if err != nil {
+			fn(nil, err)
+		}
+
+		if progress != nil {
+			switch {
+			case done:
+				progress(len(data), len(data))
+			case r.Len() > 0:
+				progress(r.Len(), 0)
+			}
+		}
+
+		if done {
+			fn(data, nil)
+		}
+	})
+	removeEvent := dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if e.IsRFEvent() && e.Code == EVENT_TRANSFER_RX_FAILED {
+			if err := r.Fail(); err != nil {
+				fn(nil, err)
+			}
+		}
+	})
+
+	return func() {
+		removeMessage()
+		removeEvent()
+	}
+}
+
+// ReadBurst waits for the next burst transfer on the channel and returns it. It returns an error
+// wrapping ErrBurstFailed if the transfer fails, or the error of ctx if it's done first.
+func (dev *Ant) ReadBurst(ctx context.Context, channel uint8) (Packet, error) {
+	type result struct {
+		data Packet
+		err  error
+	}
+	results := make(chan result, 1)
+
+	defer dev.OnBurst(channel, func(data Packet, err error) {
+		select {
+		case results <- result{data, err}:
+		default:
+		}
+	})()
+
+	select {
+	case r := <-results:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
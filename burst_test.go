@@ -0,0 +1,134 @@
+/*
+ * burst_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnBurstWithProgress(t *testing.T) {
+	data := make(Packet, 24)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	drv := &testDriver{}
+	drv.feed(
+		burstTransferPacket(1|SEQUENCE_FIRST_MESSAGE, data[0:8]),
+		burstTransferPacket(1|SEQUENCE_NUMBER_INC, data[8:16]),
+		burstTransferPacket(1|2*SEQUENCE_NUMBER_INC|SEQUENCE_LAST_MESSAGE, data[16:24]),
+	)
+
+	dev := MakeAnt(drv, nil, WithSilent())
+
+	type call struct{ transferred, total int }
+	var calls []call
+	received := make(chan Packet, 1)
+	dev.OnBurstWithProgress(1, func(data Packet, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		received <- data
+	}, func(transferred int, total int) {
+		calls = append(calls, call{transferred, total})
+	})
+
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Stop()
+
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, data) {
+			t.Errorf("got %s, want %s", got, data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("burst not received")
+	}
+
+	want := []call{{8, 0}, {16, 0}, {24, 24}}
+	if len(calls) != len(want) {
+		t.Fatalf("got progress %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("got progress %v, want %v", calls, want)
+			break
+		}
+	}
+}
+
+// failingBurstDriver is a SimDriver reporting EVENT_TRANSFER_TX_FAILED once failAt burst packets
+// were written, and holding the next write until the Ant has seen the event.
+type failingBurstDriver struct {
+	*SimDriver
+	failAt  int
+	packets int
+	seen    chan struct{}
+}
+
+func (d *failingBurstDriver) Write(b []byte) (int, error) {
+	n, err := d.SimDriver.Write(b)
+
+	var decoder Decoder
+	_, _ = decoder.Write(b)
+	for msg, _ := decoder.Next(); msg != nil; msg, _ = decoder.Next() {
+		if msg.Id != MESG_BURST_DATA_ID {
+			continue
+		}
+		if d.packets++; d.packets == d.failAt {
+			d.mu.Lock()
+			d.event(msg.Channel(), EVENT_TRANSFER_TX_FAILED)
+			// Handled after the failure, so every handler of the failure has run once it is seen
+			d.event(7, EVENT_RX_SEARCH_TIMEOUT)
+			d.mu.Unlock()
+			<-d.seen
+		}
+	}
+	return n, err
+}
+
+func TestSendBurstTransferFailure(t *testing.T) {
+	drv := &failingBurstDriver{SimDriver: NewSimDriver(), failAt: 3, seen: make(chan struct{})}
+	dev := startTestAnt(t, drv)
+	dev.OnChannelEvent(7, func(ChannelEvent) { close(drv.seen) })
+
+	err := dev.SendBurstTransfer(1, make(Packet, 80))
+	if !errors.Is(err, ErrBurstFailed) {
+		t.Fatalf("got %v, want %v", err, ErrBurstFailed)
+	}
+
+	// The packet queued while the failure was on its way still goes out
+	dev.Stop()
+	if drv.packets < drv.failAt || drv.packets > drv.failAt+1 {
+		t.Errorf("%d packets sent, want at most one after the failure", drv.packets)
+	}
+	if sent := fmt.Sprintf("%d of 80 bytes", drv.packets*8); !strings.Contains(err.Error(), sent) {
+		t.Errorf("error %q doesn't tell the %s sent", err, sent)
+	}
+}
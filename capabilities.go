@@ -0,0 +1,114 @@
+/*
+ * capabilities.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+// Capabilities is the content of a MESG_CAPABILITIES_ID message. Older modules send a
+// shorter message, in which case the missing option bytes are left zero.
+type Capabilities struct {
+	MaxChannels          uint8
+	MaxNetworks          uint8
+	StandardOptions      uint8 // CAPABILITIES_NO_* flags
+	AdvancedOptions      uint8
+	AdvancedOptions2     uint8
+	MaxSensRcoreChannels uint8
+	AdvancedOptions3     uint8
+	AdvancedOptions4     uint8
+
+	// Decoded from the advanced options 2 byte
+	ExtendedMessages bool // Channel ID, RSSI and timestamp extensions of received data, see ExtendedData
+	ScanMode         bool
+	ProximitySearch  bool
+	ExtendedAssign   bool // Extended assignment flags, e.g. EXT_PARAM_FAST_CHANNEL_INIT, EXT_PARAM_ASYNC_TX
+	ANTFS            bool
+
+	// Decoded from the advanced options 3 byte
+	AdvancedBurst       bool
+	EventBuffering      bool
+	EventFiltering      bool
+	HighDutySearch      bool
+	SearchSharing       bool
+	SelectiveDataUpdate bool
+	EncryptedChannel    bool
+
+	// Decoded from the advanced options 4 byte
+	RFActiveNotification bool
+}
+
+// AsCapabilities decodes a MESG_CAPABILITIES_ID message.
+func (m Message) AsCapabilities() (c Capabilities, ok bool) {
+	if m.Id != MESG_CAPABILITIES_ID || len(m.Data) < 4 {
+		return c, false
+	}
+
+	fields := []*uint8{
+		&c.MaxChannels, &c.MaxNetworks, &c.StandardOptions, &c.AdvancedOptions,
+		&c.AdvancedOptions2, &c.MaxSensRcoreChannels, &c.AdvancedOptions3, &c.AdvancedOptions4,
+	}
+	for i := 0; i < len(fields) && i < len(m.Data); i++ {
+		*fields[i] = m.Data[i]
+	}
+
+	c.ExtendedMessages = c.AdvancedOptions2&CAPABILITIES_EXT_MESSAGE_ENABLED != 0
+	c.ScanMode = c.AdvancedOptions2&CAPABILITIES_SCAN_MODE_ENABLED != 0
+	c.ProximitySearch = c.AdvancedOptions2&CAPABILITIES_PROX_SEARCH_ENABLED != 0
+	c.ExtendedAssign = c.AdvancedOptions2&CAPABILITIES_EXT_ASSIGN_ENABLED != 0
+	c.ANTFS = c.AdvancedOptions2&CAPABILITIES_FS_ANTFS_ENABLED != 0
+
+	c.AdvancedBurst = c.AdvancedOptions3&CAPABILITIES_ADVANCED_BURST_ENABLED != 0
+	c.EventBuffering = c.AdvancedOptions3&CAPABILITIES_EVENT_BUFFERING_ENABLED != 0
+	c.EventFiltering = c.AdvancedOptions3&CAPABILITIES_EVENT_FILTERING_ENABLED != 0
+	c.HighDutySearch = c.AdvancedOptions3&CAPABILITIES_HIGH_DUTY_SEARCH_MODE_ENABLED != 0
+	c.SearchSharing = c.AdvancedOptions3&CAPABILITIES_ACTIVE_SEARCH_SHARING_MODE_ENABLED != 0
+	c.SelectiveDataUpdate = c.AdvancedOptions3&CAPABILITIES_SELECTIVE_DATA_UPDATE_ENABLED != 0
+	c.EncryptedChannel = c.AdvancedOptions3&CAPABILITIES_ENCRYPTED_CHANNEL_ENABLED != 0
+
+	c.RFActiveNotification = c.AdvancedOptions4&CAPABILITIES_RFACTIVE_NOTIFICATION_ENABLED != 0
+
+	return c, true
+}
+
+// RequestCapabilities asks the module for its capabilities. Once the response has been
+// received it is available from Capabilities.
+func (dev *Ant) RequestCapabilities() {
+	dev.RequestMessage(0, MESG_CAPABILITIES_ID)
+}
+
+// Capabilities returns the capabilities last reported by the module, ok is false
+// if they haven't been received yet.
+func (dev *Ant) Capabilities() (c Capabilities, ok bool) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if dev.capabilities == nil {
+		return c, false
+	}
+	return *dev.capabilities, true
+}
+
+// requireAdvancedOptions3 returns ErrUnsupportedByDevice if the module reported capabilities
+// without the given advanced options 3 flag. Before the capabilities are known everything is allowed.
+func (dev *Ant) requireAdvancedOptions3(flag uint8) error {
+	if c, ok := dev.Capabilities(); ok && c.AdvancedOptions3&flag == 0 {
+		return ErrUnsupportedByDevice
+	}
+	return nil
+}
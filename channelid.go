@@ -0,0 +1,92 @@
+/*
+ * channelid.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Channel ID byte layout
+//
+// A channel ID is made of a 16-bit device number, a device type and a transmission type:
+//
+//	device type:        bit 7    pairing bit
+//	                    bits 0-6 device type (e.g. 120 for an ANT+ HRM)
+//	transmission type:  bits 4-7 most significant nibble of a 20-bit device number
+//	                    bit 3    reserved
+//	                    bit 2    global data pages are used
+//	                    bits 0-1 channel type: 01 independent, 10 1-byte shared, 11 2-byte shared
+//
+// A master must use a complete ID, while on a slave zero fields are wildcards.
+
+// MakeTransmissionType builds the transmission type of an independent channel. deviceNumMSN is
+// the nibble extending the device number to 20 bits and must fit in 4 bits, otherwise it panics.
+// Note that the pairing bit isn't part of the transmission type, it lives in the device type (see MakeDeviceType).
+func MakeTransmissionType(globalDataPages bool, deviceNumMSN uint8) uint8 {
+	if deviceNumMSN > 0x0F {
+		panic(fmt.Sprint("Device number extension should fit in 4 bits not ", deviceNumMSN))
+	}
+
+	transmissionType := ANT_TRANS_TYPE_INDEPENDENT_CHANNEL | deviceNumMSN<<4
+	if globalDataPages {
+		transmissionType |= ANT_TRANS_TYPE_GLOBAL_DATA_PAGES
+	}
+	return transmissionType
+}
+
+// MakeDeviceType builds the device type byte, setting the pairing bit if requested.
+// It panics if deviceType doesn't fit in 7 bits.
+func MakeDeviceType(deviceType uint8, pairing bool) uint8 {
+	if deviceType&ANT_ID_DEVICE_TYPE_PAIRING_FLAG != 0 {
+		panic(fmt.Sprint("Device type should fit in 7 bits not ", deviceType))
+	}
+
+	if pairing {
+		deviceType |= ANT_ID_DEVICE_TYPE_PAIRING_FLAG
+	}
+	return deviceType
+}
+
+// ChannelID identifies the device a channel talks to.
+type ChannelID struct {
+	DeviceNumber     uint16
+	DeviceType       uint8
+	TransmissionType uint8
+}
+
+// AsChannelID decodes a MESG_CHANNEL_ID_ID message, e.g. the reply to
+// RequestMessage(channel, MESG_CHANNEL_ID_ID) that tells which device a slave channel found.
+func (m Message) AsChannelID() (id ChannelID, ok bool) {
+	if m.Id != MESG_CHANNEL_ID_ID || len(m.Data) < MESG_CHANNEL_ID_SIZE {
+		return id, false
+	}
+	return channelIDFromBytes(m.Data[1:]), true
+}
+
+func channelIDFromBytes(b []byte) ChannelID {
+	return ChannelID{
+		DeviceNumber:     binary.LittleEndian.Uint16(b),
+		DeviceType:       b[2],
+		TransmissionType: b[3],
+	}
+}
@@ -0,0 +1,454 @@
+/*
+ * config.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NetworkConfig is a network number and its key.
+type NetworkConfig struct {
+	Number uint8
+	Key    [8]uint8
+}
+
+// ChannelConfig is the setup of a channel. Optional settings are nil when they were never set,
+// leaving the module default in place.
+type ChannelConfig struct {
+	Channel  uint8
+	Type     uint8
+	Network  uint8
+	ExtFlags uint8 `json:",omitempty"`
+
+	ID            *ChannelID `json:",omitempty"`
+	Period        *uint16    `json:",omitempty"`
+	RFFreq        *uint8     `json:",omitempty"`
+	SearchTimeout *uint8     `json:",omitempty"`
+	AgilityFreqs  *[3]uint8  `json:",omitempty"` // See ConfigAutoFrequency
+
+	Open bool
+}
+
+// Config is a snapshot of the device setup, as sent through the Ant since it was started or last reset.
+// It round-trips through encoding/json.
+type Config struct {
+	Networks []NetworkConfig
+	Channels []ChannelConfig
+}
+
+// ExportConfig returns the configuration sent to the device so far.
+func (dev *Ant) ExportConfig() (c Config) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	for number, key := range dev.networks {
+		c.Networks = append(c.Networks, NetworkConfig{Number: number, Key: key})
+	}
+	sort.Slice(c.Networks, func(i, j int) bool { return c.Networks[i].Number < c.Networks[j].Number })
+
+	for _, channel := range dev.channels {
+		c.Channels = append(c.Channels, channel.clone())
+	}
+	sort.Slice(c.Channels, func(i, j int) bool { return c.Channels[i].Channel < c.Channels[j].Channel })
+
+	return c
+}
+
+// ImportConfig sends a configuration, e.g. one from ExportConfig, to the device: the network keys
+// first, then every channel is assigned, configured, and opened if it was open.
+// Channels are checked like AssignChannel does, if one has an invalid type or a network whose
+// key is neither in the configuration nor set, its error is returned and nothing is sent.
+// It stops early if ctx is done, returning ctx.Err().
+func (dev *Ant) ImportConfig(ctx context.Context, c Config) error {
+	for _, channel := range c.Channels {
+		if err := dev.checkChannelConfig(channel, c.Networks); err != nil {
+			return fmt.Errorf("Channel %d: %w", channel.Channel, err)
+		}
+	}
+
+	for _, message := range c.messages() {
+		if err := dev.queueContext(ctx, PriorityConfig, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkChannelConfig checks a channel configuration like AssignChannel checks its arguments,
+// the keys of networks being about to be set.
+func (dev *Ant) checkChannelConfig(c ChannelConfig, networks []NetworkConfig) error {
+	if err := checkChannelType(c.Type); err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		if network.Number == c.Network {
+			return nil
+		}
+	}
+	return dev.checkNetwork(c.Network)
+}
+
+// ChannelErrors holds the error of each channel that failed, see BatchConfigure.
+type ChannelErrors map[uint8]error
+
+func (e ChannelErrors) Error() string {
+	channels := make([]int, 0, len(e))
+	for channel := range e {
+		channels = append(channels, int(channel))
+	}
+	sort.Ints(channels)
+
+	errs := make([]string, len(channels))
+	for i, channel := range channels {
+		errs[i] = fmt.Sprintf("channel %d: %v", channel, e[uint8(channel)])
+	}
+	return "Configuration failed on " + strings.Join(errs, ", ")
+}
+
+// BatchConfigure assigns, configures and opens (if Open is set) several channels at once: all the
+// commands are queued as one batch, packed into as few driver writes as possible, then the responses
+// are collected. It returns ChannelErrors with the first rejected command of each failing channel,
+// or the error of ctx if it's done before every response came in. Channels are checked like
+// AssignChannel does first, if any has an invalid type or a network whose key isn't set, their
+// errors are returned as ChannelErrors and nothing is sent.
+func (dev *Ant) BatchConfigure(ctx context.Context, configs []ChannelConfig) error {
+	invalid := make(ChannelErrors)
+	for _, c := range configs {
+		if err := dev.checkChannelConfig(c, nil); err != nil {
+			invalid[c.Channel] = err
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+
+	var messages []*Message
+
+	var mu sync.Mutex
+	pending := 0
+	expected := make(map[uint8]map[uint8]int) // Responses still expected per channel and command
+	errs := make(ChannelErrors)
+	done := make(chan struct{})
+
+	for _, c := range configs {
+		channelMessages := c.messages()
+		messages = append(messages, channelMessages...)
+
+		if expected[c.Channel] == nil {
+			expected[c.Channel] = make(map[uint8]int)
+		}
+		for _, m := range channelMessages {
+			expected[c.Channel][m.Id]++
+			pending++
+		}
+	}
+
+	if pending == 0 {
+		return nil
+	}
+
+	for channel := range expected {
+		channel := channel
+		defer dev.OnChannelEvent(channel, func(e ChannelEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if e.IsRFEvent() || expected[channel][e.MessageID] == 0 {
+				return
+			}
+			expected[channel][e.MessageID]--
+
+			if e.Code != RESPONSE_NO_ERROR && errs[channel] == nil {
+				errs[channel] = ResponseError(e)
+			}
+
+			if pending--; pending == 0 {
+				close(done)
+			}
+		})()
+	}
+
+	if err := dev.queueBatchContext(ctx, PriorityConfig, messages); err != nil {
+		return err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ChannelUpdate holds the settings UpdateChannel changes, nil fields are left as they are.
+type ChannelUpdate struct {
+	Period *uint16 // in 1/32768s counts
+	RFFreq *uint8  // offset from 2400MHz
+}
+
+// UpdateChannel changes the period and RF frequency of an assigned channel. An open channel is
+// closed first, waiting for EVENT_CHANNEL_CLOSED, and reopened afterwards, which works whether
+// or not the module accepts the change while open. The channel ID and the rest of the setup are kept.
+func (dev *Ant) UpdateChannel(channel uint8, cfg ChannelUpdate) error {
+	if err := dev.checkChannel(channel); err != nil {
+		return err
+	}
+
+	dev.mu.Lock()
+	c, ok := dev.channels[channel]
+	open := ok && c.Open
+	dev.mu.Unlock()
+
+	if open {
+		ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+		defer cancel()
+
+		if err := dev.CloseChannelSync(ctx, channel); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Period != nil {
+		dev.SetChannelPeriod(channel, *cfg.Period)
+	}
+	if cfg.RFFreq != nil {
+		dev.SetChannelRFFreq(channel, *cfg.RFFreq)
+	}
+
+	if open {
+		dev.OpenChannel(channel)
+	}
+	return nil
+}
+
+// messages returns the commands recreating the configuration.
+func (c Config) messages() (messages []*Message) {
+	for _, network := range c.Networks {
+		payload := [9]byte{network.Number}
+		copy(payload[1:], network.Key[:])
+		messages = append(messages, NewMessage(MESG_NETWORK_KEY_ID, payload[:]))
+	}
+
+	for _, channel := range c.Channels {
+		messages = append(messages, channel.messages()...)
+	}
+	return messages
+}
+
+func (c ChannelConfig) messages() (messages []*Message) {
+	if c.ExtFlags != 0 {
+		messages = append(messages, NewMessage(MESG_ASSIGN_CHANNEL_ID, Packet{c.Channel, c.Type, c.Network, c.ExtFlags}))
+	} else {
+		messages = append(messages, NewMessage(MESG_ASSIGN_CHANNEL_ID, Packet{c.Channel, c.Type, c.Network}))
+	}
+
+	if c.ID != nil {
+		payload := [5]byte{c.Channel, 0, 0, c.ID.DeviceType, c.ID.TransmissionType}
+		binary.LittleEndian.PutUint16(payload[1:], c.ID.DeviceNumber)
+		messages = append(messages, NewMessage(MESG_CHANNEL_ID_ID, payload[:]))
+	}
+
+	if c.Period != nil {
+		payload := [3]byte{c.Channel}
+		binary.LittleEndian.PutUint16(payload[1:], *c.Period)
+		messages = append(messages, NewMessage(MESG_CHANNEL_MESG_PERIOD_ID, payload[:]))
+	}
+
+	if c.RFFreq != nil {
+		messages = append(messages, NewMessage(MESG_CHANNEL_RADIO_FREQ_ID, Packet{c.Channel, *c.RFFreq}))
+	}
+
+	if c.SearchTimeout != nil {
+		messages = append(messages, NewMessage(MESG_CHANNEL_SEARCH_TIMEOUT_ID, Packet{c.Channel, *c.SearchTimeout}))
+	}
+
+	if f := c.AgilityFreqs; f != nil {
+		messages = append(messages, NewMessage(MESG_AUTO_FREQ_CONFIG_ID, Packet{c.Channel, f[0], f[1], f[2]}))
+	}
+
+	if c.Open {
+		messages = append(messages, NewMessage(MESG_OPEN_CHANNEL_ID, Packet{c.Channel}))
+	}
+	return messages
+}
+
+func (c *ChannelConfig) clone() ChannelConfig {
+	clone := *c
+	if c.ID != nil {
+		id := *c.ID
+		clone.ID = &id
+	}
+	if c.Period != nil {
+		period := *c.Period
+		clone.Period = &period
+	}
+	if c.RFFreq != nil {
+		freq := *c.RFFreq
+		clone.RFFreq = &freq
+	}
+	if c.SearchTimeout != nil {
+		timeout := *c.SearchTimeout
+		clone.SearchTimeout = &timeout
+	}
+	if c.AgilityFreqs != nil {
+		freqs := *c.AgilityFreqs
+		clone.AgilityFreqs = &freqs
+	}
+	return clone
+}
+
+// trackConfig records the configuration carried by an outgoing message.
+func (dev *Ant) trackConfig(m *Message) {
+	d := m.Data
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if dev.networks == nil {
+		dev.networks = make(map[uint8][8]uint8)
+		dev.channels = make(map[uint8]*ChannelConfig)
+	}
+
+	channel := func() *ChannelConfig {
+		c, ok := dev.channels[d[0]]
+		if !ok {
+			c = &ChannelConfig{Channel: d[0]}
+			dev.channels[d[0]] = c
+		}
+		return c
+	}
+
+	switch {
+	case m.Id == MESG_SYSTEM_RESET_ID:
+		dev.networks = make(map[uint8][8]uint8)
+		dev.channels = make(map[uint8]*ChannelConfig)
+		dev.txPower = nil
+		dev.libConfig = 0
+		for channel := 0; channel <= 0xFF; channel++ {
+			dev.forgetDevice(uint8(channel))
+		}
+
+	case m.Id == MESG_RADIO_TX_POWER_ID && len(d) >= MESG_RADIO_TX_POWER_SIZE:
+		power := d[1]
+		dev.txPower = &power
+
+	case m.Id == MESG_ANTLIB_CONFIG_ID && len(d) >= MESG_ANTLIB_CONFIG_SIZE:
+		dev.libConfig = d[1]
+
+	case m.Id == MESG_NETWORK_KEY_ID && len(d) >= MESG_NETWORK_KEY_SIZE:
+		var key [8]uint8
+		copy(key[:], d[1:])
+		dev.networks[d[0]] = key
+
+	case m.Id == MESG_ASSIGN_CHANNEL_ID && len(d) >= MESG_ASSIGN_CHANNEL_SIZE:
+		c := &ChannelConfig{Channel: d[0], Type: d[1], Network: d[2]}
+		if len(d) > MESG_ASSIGN_CHANNEL_SIZE {
+			c.ExtFlags = d[3]
+		}
+		dev.channels[d[0]] = c
+		dev.forgetDevice(d[0])
+
+	case m.Id == MESG_UNASSIGN_CHANNEL_ID && len(d) >= MESG_UNASSIGN_CHANNEL_SIZE:
+		delete(dev.channels, d[0])
+		dev.forgetDevice(d[0])
+
+	case m.Id == MESG_CHANNEL_ID_ID && len(d) >= MESG_CHANNEL_ID_SIZE:
+		id := channelIDFromBytes(d[1:])
+		c := channel()
+		if c.ID == nil || *c.ID != id {
+			dev.forgetDevice(d[0])
+		}
+		c.ID = &id
+
+	case m.Id == MESG_CHANNEL_MESG_PERIOD_ID && len(d) >= MESG_CHANNEL_MESG_PERIOD_SIZE:
+		period := binary.LittleEndian.Uint16(d[1:])
+		channel().Period = &period
+
+	case m.Id == MESG_CHANNEL_RADIO_FREQ_ID && len(d) >= MESG_CHANNEL_RADIO_FREQ_SIZE:
+		freq := d[1]
+		channel().RFFreq = &freq
+
+	case m.Id == MESG_CHANNEL_SEARCH_TIMEOUT_ID && len(d) >= MESG_CHANNEL_SEARCH_TIMEOUT_SIZE:
+		timeout := d[1]
+		channel().SearchTimeout = &timeout
+
+	case m.Id == MESG_AUTO_FREQ_CONFIG_ID && len(d) >= MESG_AUTO_FREQ_CONFIG_SIZE:
+		channel().AgilityFreqs = &[3]uint8{d[1], d[2], d[3]}
+
+	case m.Id == MESG_OPEN_CHANNEL_ID && len(d) >= MESG_OPEN_CHANNEL_SIZE:
+		channel().Open = true
+
+	case m.Id == MESG_CLOSE_CHANNEL_ID && len(d) >= MESG_CLOSE_CHANNEL_SIZE:
+		channel().Open = false
+	}
+}
+
+// forgetDevice drops what was recorded about the device on the channel: its last message, observed
+// pages, timing, quality and deduplicated payload, so a channel reused for another device doesn't
+// report stale data. Every per-channel cache is cleared here. dev.mu must be held.
+func (dev *Ant) forgetDevice(channel uint8) {
+	delete(dev.last, channel)
+	delete(dev.pages, channel)
+	delete(dev.timing, channel)
+	delete(dev.quality, channel)
+	if state, ok := dev.dedup[channel]; ok {
+		*state = dedupState{}
+	}
+}
+
+// FrequencyAgilityConfig returns the frequencies set with ConfigAutoFrequency on the channel,
+// as sent since the module doesn't report them. ok is false if none were set.
+func (dev *Ant) FrequencyAgilityConfig(channel uint8) (freqs [3]uint8, ok bool) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if c, assigned := dev.channels[channel]; assigned && c.AgilityFreqs != nil {
+		return *c.AgilityFreqs, true
+	}
+	return freqs, false
+}
+
+// TransmitPower returns the transmit power last set with SetTransmitPower. The module has no
+// message to report it, so ok is false if none was set since the last reset, the module then
+// uses its default power.
+func (dev *Ant) TransmitPower() (power uint8, ok bool) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if dev.txPower == nil {
+		return 0, false
+	}
+	return *dev.txPower, true
+}
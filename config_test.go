@@ -0,0 +1,192 @@
+/*
+ * config_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestImportConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    error
+	}{
+		{
+			name:   "invalid channel type",
+			config: Config{Channels: []ChannelConfig{{Channel: 0, Type: 0x60}}},
+			err:    ErrInvalidChannelType,
+		},
+		{
+			name:   "network key not set",
+			config: Config{Channels: []ChannelConfig{{Channel: 0, Type: ChannelTypeBidirectionalSlave, Network: 1}}},
+			err:    ErrNetworkKeyNotSet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drv := &testDriver{}
+			dev := startTestAnt(t, drv)
+
+			if err := dev.ImportConfig(context.Background(), tt.config); !errors.Is(err, tt.err) {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			dev.Stop()
+			if written := drv.written(t); len(written) != 0 {
+				t.Errorf("%d messages sent for an invalid configuration", len(written))
+			}
+		})
+	}
+
+	// A network set by the configuration itself is fine
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+	c := Config{
+		Networks: []NetworkConfig{{Number: 1, Key: AntPlusNetworkKey()}},
+		Channels: []ChannelConfig{{Channel: 0, Type: ChannelTypeBidirectionalSlave, Network: 1}},
+	}
+	if err := dev.ImportConfig(context.Background(), c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchConfigureValidation(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+
+	err := dev.BatchConfigure(context.Background(), []ChannelConfig{
+		{Channel: 0, Type: ChannelTypeBidirectionalSlave},
+		{Channel: 1, Type: 0x60},
+		{Channel: 2, Type: ChannelTypeBidirectionalSlave, Network: 1},
+	})
+
+	var errs ChannelErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want ChannelErrors", err)
+	}
+	if len(errs) != 2 || !errors.Is(errs[1], ErrInvalidChannelType) || !errors.Is(errs[2], ErrNetworkKeyNotSet) {
+		t.Errorf("got %v", errs)
+	}
+
+	dev.Stop()
+	if written := drv.written(t); len(written) != 0 {
+		t.Errorf("%d messages sent for an invalid configuration", len(written))
+	}
+}
+
+func TestChannelReusedForAnotherDevice(t *testing.T) {
+	constant := func(page uint8) func() [8]byte {
+		return func() [8]byte { return [8]byte{page, 0xFF, 0xFF, 0, 0, 0, 0, 60} }
+	}
+	sim := NewSimDriver(
+		SimSensor{ID: ChannelID{DeviceNumber: 1, DeviceType: 120, TransmissionType: 1}, Payload: constant(0x04)},
+		SimSensor{ID: ChannelID{DeviceNumber: 2, DeviceType: 11, TransmissionType: 5}, Payload: constant(0x10)},
+	)
+	dev := startTestAnt(t, sim, WithDedup(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	track := func(deviceType uint8) {
+		t.Helper()
+
+		received := make(chan struct{}, 1)
+		remove := dev.OnMessage(MESG_BROADCAST_DATA_ID, func(*Message) {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		})
+		defer remove()
+
+		dev.SetChannelId(0, 0, deviceType, 0)
+		if err := dev.OpenChannelSync(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatalf("no broadcast from device type %d", deviceType)
+		}
+		if err := dev.CloseChannelSync(ctx, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetChannelPeriod(0, 1024)
+	track(120)
+	if got := dev.ObservedPages(0); len(got) != 1 || got[0] != 0x04 {
+		t.Errorf("HRM: got observed pages %v, want [4]", got)
+	}
+	track(11)
+	if got := dev.ObservedPages(0); len(got) != 1 || got[0] != 0x10 {
+		t.Errorf("power meter: got observed pages %v, want [16]", got)
+	}
+
+	// Switching to another device forgets everything about the previous one
+	dev.SetChannelId(0, 0, 121, 0)
+	if _, ok := dev.LastMessage(0); ok {
+		t.Error("last message kept")
+	}
+	if got := dev.ObservedPages(0); len(got) != 0 {
+		t.Errorf("observed pages %v kept", got)
+	}
+	if _, ok := dev.ChannelTiming(0); ok {
+		t.Error("timing kept")
+	}
+	if q := dev.ChannelQuality(0); q != (ChannelQuality{}) {
+		t.Errorf("quality %+v kept", q)
+	}
+	// The new device may well start with the payload the previous one sent last
+	payload := constant(0x10)()
+	if dev.isDuplicate(NewMessage(MESG_BROADCAST_DATA_ID, append(Packet{0}, payload[:]...))) {
+		t.Error("deduplicated payload kept")
+	}
+}
+
+func TestResetForgetsEveryChannel(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv, WithDedup(2))
+
+	// Channel 1 only has reception statistics, channel 2 only a deduplicated payload
+	dev.process(NewMessage(MESG_RESPONSE_EVENT_ID, Packet{1, MESG_EVENT_ID, EVENT_RX_FAIL}))
+	dev.isDuplicate(NewMessage(MESG_BROADCAST_DATA_ID, Packet{2, 1, 2, 3, 4, 5, 6, 7, 8}))
+	if q := dev.ChannelQuality(1); q.RxFail != 1 {
+		t.Fatalf("got %d RX failures, want 1", q.RxFail)
+	}
+
+	dev.ResetSystem()
+
+	if q := dev.ChannelQuality(1); q != (ChannelQuality{}) {
+		t.Errorf("quality %+v kept", q)
+	}
+	if dev.isDuplicate(NewMessage(MESG_BROADCAST_DATA_ID, Packet{2, 1, 2, 3, 4, 5, 6, 7, 8})) {
+		t.Error("deduplicated payload kept")
+	}
+}
@@ -79,8 +79,11 @@ const (
 	ANT_ID_DEVICE_TYPE_PAIRING_FLAG  uint8 = 0x80
 
 	ANT_TRANS_TYPE_SHARED_ADDR_MASK      uint8 = 0x03
+	ANT_TRANS_TYPE_INDEPENDENT_CHANNEL   uint8 = 0x01
 	ANT_TRANS_TYPE_1_BYTE_SHARED_ADDRESS uint8 = 0x02
 	ANT_TRANS_TYPE_2_BYTE_SHARED_ADDRESS uint8 = 0x03
+	ANT_TRANS_TYPE_GLOBAL_DATA_PAGES     uint8 = 0x04
+	ANT_TRANS_TYPE_DEVICE_NUMBER_MASK    uint8 = 0xF0 // Extends the device number to 20 bits
 
 	//////////////////////////////////////////////
 	// Assign Channel Parameters
@@ -97,8 +100,11 @@ const (
 	// Ext. Assign Channel Parameters
 	//////////////////////////////////////////////
 
-	EXT_PARAM_ALWAYS_SEARCH     uint8 = 0x01
-	EXT_PARAM_FREQUENCY_AGILITY uint8 = 0x04
+	EXT_PARAM_ALWAYS_SEARCH       uint8 = 0x01
+	EXT_PARAM_BACKGROUND_SCANNING uint8 = 0x01 // Same bit as EXT_PARAM_ALWAYS_SEARCH
+	EXT_PARAM_FREQUENCY_AGILITY   uint8 = 0x04
+	EXT_PARAM_FAST_CHANNEL_INIT   uint8 = 0x10
+	EXT_PARAM_ASYNC_TX            uint8 = 0x20
 
 	//////////////////////////////////////////////
 	// Radio TX Power Definitions
@@ -170,6 +176,12 @@ const (
 	CAPABILITIES_SELECTIVE_DATA_UPDATE_ENABLED      uint8 = 0x40
 	CAPABILITIES_ENCRYPTED_CHANNEL_ENABLED          uint8 = 0x80
 
+	//////////////////////////////////////////////
+	// Advanced capabilities 4 defines
+	//////////////////////////////////////////////
+
+	CAPABILITIES_RFACTIVE_NOTIFICATION_ENABLED uint8 = 0x01
+
 	//////////////////////////////////////////////
 	// Burst Message Sequence
 	//////////////////////////////////////////////
@@ -187,6 +199,21 @@ const (
 
 	ADV_BURST_CONFIG_FREQ_HOP uint32 = 0x00000001
 
+	//////////////////////////////////////////////
+	// Event Filter defines, a set bit suppresses the event
+	//////////////////////////////////////////////
+
+	EVENT_FILTER_RX_SEARCH_TIMEOUT     uint16 = 0x0001
+	EVENT_FILTER_RX_FAIL               uint16 = 0x0002
+	EVENT_FILTER_TX                    uint16 = 0x0004
+	EVENT_FILTER_TRANSFER_RX_FAILED    uint16 = 0x0008
+	EVENT_FILTER_TRANSFER_TX_COMPLETED uint16 = 0x0010
+	EVENT_FILTER_TRANSFER_TX_FAILED    uint16 = 0x0020
+	EVENT_FILTER_CHANNEL_CLOSED        uint16 = 0x0040
+	EVENT_FILTER_RX_FAIL_GO_TO_SEARCH  uint16 = 0x0080
+	EVENT_FILTER_CHANNEL_COLLISION     uint16 = 0x0100
+	EVENT_FILTER_TRANSFER_TX_START     uint16 = 0x0200
+
 	//////////////////////////////////////////////
 	// Extended Message ID Mask
 	//////////////////////////////////////////////
@@ -0,0 +1,90 @@
+/*
+ * decoder.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+// Decoder reassembles messages from a stream of bytes that can be split anywhere,
+// e.g. a frame starting at the end of one driver read and ending in the next.
+// The zero value is ready to use.
+type Decoder struct {
+	buf Packet // Bytes not decoded yet
+	mem Packet // The whole space buf lives in
+}
+
+// Write appends received bytes to the stream, it never fails.
+func (d *Decoder) Write(p []byte) (int, error) {
+	if need := len(d.buf) + len(p); need > cap(d.buf) {
+		if need <= cap(d.mem) {
+			// Move the pending bytes back to the start rather than growing the buffer
+			d.buf = d.mem[:copy(d.mem[:len(d.buf)], d.buf)]
+		} else {
+			d.mem = make(Packet, 0, 2*need)
+			d.buf = append(d.mem, d.buf...)
+		}
+	}
+
+	d.buf = append(d.buf, p...)
+	return len(p), nil
+}
+
+// Buffered returns the number of bytes waiting for the rest of their frame.
+func (d *Decoder) Buffered() int {
+	return len(d.buf)
+}
+
+// Next returns the next complete message of the stream, or nil if more bytes are needed.
+// Garbage before a sync byte is skipped. A corrupt frame is skipped as well and reported
+// as an error, Next can be called again right after to continue with the following bytes.
+func (d *Decoder) Next() (*Message, error) {
+	_, msg, err := d.next()
+	return msg, err
+}
+
+// next is Next also returning the frame the message or error comes from, nil if more bytes are needed.
+func (d *Decoder) next() (frame Packet, msg *Message, err error) {
+	// Wait for TX Sync
+	i := 0
+	for i < len(d.buf) && d.buf[i] != MESG_TX_SYNC {
+		i++
+	}
+	d.buf = d.buf[i:]
+
+	if len(d.buf) <= MESG_SIZE_OFFSET {
+		return nil, nil, nil
+	}
+
+	size := int(d.buf[MESG_SIZE_OFFSET]) + MESG_FRAME_SIZE
+	if len(d.buf) < size {
+		return nil, nil, nil
+	}
+
+	// Don't keep the message pointing into the buffer
+	frame = append(Packet(nil), d.buf[:size]...)
+
+	if msg, err = Decode(frame); err != nil {
+		// The sync byte may have been part of the data, resync right after it
+		d.buf = d.buf[1:]
+		return frame, nil, err
+	}
+
+	d.buf = d.buf[size:]
+	return frame, msg, nil
+}
@@ -0,0 +1,110 @@
+/*
+ * decoder_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bytes"
+	"testing"
+)
+
+// streamDriver is a Driver endlessly reading the same frames, as fast as they are read.
+type streamDriver struct {
+	stream Packet
+	offset int
+}
+
+func (d *streamDriver) Open() error { return nil }
+
+func (d *streamDriver) Close() {}
+
+func (d *streamDriver) BufferSize() int { return 64 }
+
+func (d *streamDriver) Write(b []byte) (int, error) { return len(b), nil }
+
+func (d *streamDriver) Read(b []byte) (n int, err error) {
+	for n < len(b) {
+		copied := copy(b[n:], d.stream[d.offset:])
+		n += copied
+		d.offset = (d.offset + copied) % len(d.stream)
+	}
+	return n, nil
+}
+
+func TestDecoderSplitFrame(t *testing.T) {
+	frame := NewMessage(MESG_BROADCAST_DATA_ID, Packet{1, 0x04, 0, 0, 0, 0x10, 0x20, 5, 72}).Encode()
+
+	for i := 0; i <= len(frame); i++ {
+		var d Decoder
+		var messages []*Message
+
+		for _, part := range [][]byte{frame[:i], frame[i:]} {
+			_, _ = d.Write(part)
+			for {
+				msg, err := d.Next()
+				if err != nil {
+					t.Fatalf("split at %d: %v", i, err)
+				}
+				if msg == nil {
+					break
+				}
+				messages = append(messages, msg)
+			}
+		}
+
+		if len(messages) != 1 {
+			t.Fatalf("split at %d: got %d messages, want 1", i, len(messages))
+		}
+		if got := messages[0].Encode(); !bytes.Equal(got, frame) {
+			t.Errorf("split at %d: got %s, want %s", i, got, frame)
+		}
+		if got, want := messages[0].Checksum(), frame[len(frame)-1]; got != want {
+			t.Errorf("split at %d: got checksum 0x%02X, want 0x%02X", i, got, want)
+		}
+		if d.Buffered() != 0 {
+			t.Errorf("split at %d: %d bytes left buffered", i, d.Buffered())
+		}
+	}
+}
+
+// BenchmarkDecodeStream measures the cost per received broadcast, from the driver read to the
+// message on the read channel.
+func BenchmarkDecodeStream(b *testing.B) {
+	frame := NewMessage(MESG_BROADCAST_DATA_ID, Packet{0, 0x04, 0, 0, 0, 0x10, 0x20, 5, 72}).Encode()
+	var stream Packet
+	for i := 0; i < 64; i++ {
+		stream = append(stream, frame...)
+	}
+
+	dev := MakeAnt(&streamDriver{stream: stream}, nil, WithSilent(), WithReadBuffer(64, Block))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	if err := dev.Start(); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		<-dev.Messages()
+	}
+	b.StopTimer()
+
+	dev.Stop()
+}
@@ -35,10 +35,11 @@ type UsbDevice struct {
 	intf       *gousb.Interface
 	in         *gousb.InEndpoint
 	out        *gousb.OutEndpoint
+	logger     *log.Logger
 }
 
 func (dev *UsbDevice) Open() (e error) {
-	log.Println("Opening USB device")
+	dev.logger.Println("Opening USB device")
 
 	dev.context = gousb.NewContext()
 
@@ -75,13 +76,13 @@ func (dev *UsbDevice) Open() (e error) {
 		return
 	}
 
-	log.Println("USB Device opened")
+	dev.logger.Println("USB Device opened")
 
 	return
 }
 
 func (dev *UsbDevice) Close() {
-	log.Println("Closing USB device")
+	dev.logger.Println("Closing USB device")
 
 	if dev.closeIface != nil {
 		dev.closeIface()
@@ -94,11 +95,30 @@ func (dev *UsbDevice) Close() {
 	if dev.context != nil {
 		_ = dev.context.Close()
 	}
-	log.Println("USB Device closed")
+	dev.logger.Println("USB Device closed")
+}
+
+// SetLogger sets where the device logs to, MakeAnt passes it the logger of the Ant.
+func (dev *UsbDevice) SetLogger(logger *log.Logger) {
+	dev.logger = logger
+}
+
+// Label returns the USB device (product) string of the open device, which can be changed
+// with SetLabel to tell identical sticks apart.
+func (dev *UsbDevice) Label() (string, error) {
+	if dev.device == nil {
+		return "", errors.New("USB Device not open")
+	}
+	return dev.device.Product()
 }
 
 func (dev *UsbDevice) Read(b []byte) (int, error) {
-	return dev.in.Read(b)
+	n, err := dev.in.Read(b)
+	if err == gousb.TransferTimedOut {
+		// Nothing to read
+		return n, nil
+	}
+	return n, err
 }
 
 func (dev *UsbDevice) Write(b []byte) (int, error) {
@@ -111,8 +131,9 @@ func (dev *UsbDevice) BufferSize() int {
 
 func GetUsbDevice(vid, pid gousb.ID) *UsbDevice {
 	return &UsbDevice{
-		vid: vid,
-		pid: pid,
+		vid:    vid,
+		pid:    pid,
+		logger: log.Default(),
 	}
 }
 
@@ -0,0 +1,255 @@
+/*
+ * events.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelEvent is a channel response or event message (MESG_RESPONSE_EVENT_ID).
+// For responses to a command MessageID is the ID of that command and Code a response code
+// (e.g. RESPONSE_NO_ERROR, CHANNEL_IN_WRONG_STATE), for RF events MessageID is
+// MESG_EVENT_ID and Code is the event (e.g. EVENT_TX, EVENT_RX_FAIL).
+type ChannelEvent struct {
+	Channel   uint8
+	MessageID uint8
+	Code      uint8
+}
+
+// IsRFEvent tells if the event was generated by the radio rather than being a response to a command.
+func (e ChannelEvent) IsRFEvent() bool {
+	return e.MessageID == MESG_EVENT_ID
+}
+
+// ResponseError is a response event with an error code, for a command the module rejected.
+type ResponseError ChannelEvent
+
+func (e ResponseError) Error() string {
+	return fmt.Sprintf("Command 0x%02X on channel %d failed with response code 0x%02X", e.MessageID, e.Channel, e.Code)
+}
+
+// AsChannelEvent decodes a MESG_RESPONSE_EVENT_ID message.
+func (m Message) AsChannelEvent() (e ChannelEvent, ok bool) {
+	if m.Id != MESG_RESPONSE_EVENT_ID || len(m.Data) < MESG_RESPONSE_EVENT_SIZE {
+		return e, false
+	}
+
+	return ChannelEvent{Channel: m.Data[0], MessageID: m.Data[1], Code: m.Data[2]}, true
+}
+
+type eventHandler struct {
+	fn func(ChannelEvent)
+}
+
+// OnChannelEvent registers fn to be called for every response and event on the channel.
+// Handlers are called from the decoding goroutine, so they should return quickly.
+// The returned function unregisters the handler.
+func (dev *Ant) OnChannelEvent(channel uint8, fn func(ChannelEvent)) (remove func()) {
+	h := &eventHandler{fn}
+
+	dev.mu.Lock()
+	if dev.eventHandlers == nil {
+		dev.eventHandlers = make(map[uint8][]*eventHandler)
+	}
+	dev.eventHandlers[channel] = append(dev.eventHandlers[channel], h)
+	dev.mu.Unlock()
+
+	return func() {
+		dev.mu.Lock()
+		defer dev.mu.Unlock()
+
+		handlers := dev.eventHandlers[channel]
+		for i := range handlers {
+			if handlers[i] == h {
+				dev.eventHandlers[channel] = append(handlers[:i:i], handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnSearchTimeout registers fn to be called when the channel's search times out without finding
+// a device (EVENT_RX_SEARCH_TIMEOUT), e.g. to tell the user no sensor was found.
+// The returned function unregisters it.
+func (dev *Ant) OnSearchTimeout(channel uint8, fn func()) (remove func()) {
+	return dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if e.IsRFEvent() && e.Code == EVENT_RX_SEARCH_TIMEOUT {
+			fn()
+		}
+	})
+}
+
+// OnCollision registers fn to be called when the channel reports EVENT_CHANNEL_COLLISION, i.e. it
+// lost its timeslot to another channel of the module or to another device with the same channel ID,
+// so a message was missed. Frequent collisions suggest moving away from the interference.
+// The returned function unregisters it.
+func (dev *Ant) OnCollision(channel uint8, fn func()) (remove func()) {
+	return dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if e.IsRFEvent() && e.Code == EVENT_CHANNEL_COLLISION {
+			fn()
+		}
+	})
+}
+
+// StartupReason is the content of a MESG_STARTUP_MESG_ID message, why the module (re)started.
+// It is a bitfield of the RESET_* flags, zero for a power-on reset.
+type StartupReason uint8
+
+// PowerOn tells if the module was powered on.
+func (r StartupReason) PowerOn() bool {
+	return r == StartupReason(RESET_POR)
+}
+
+// Watchdog tells if the watchdog reset the module, which is how spurious resets show up.
+func (r StartupReason) Watchdog() bool {
+	return uint8(r)&RESET_WDT != 0
+}
+
+// Command tells if the module was reset by a command, see ResetSystem.
+func (r StartupReason) Command() bool {
+	return uint8(r)&RESET_CMD != 0
+}
+
+func (r StartupReason) String() string {
+	if r.PowerOn() {
+		return "power-on reset"
+	}
+
+	var reasons []string
+	for _, f := range []struct {
+		flag uint8
+		name string
+	}{
+		{RESET_RST, "hardware reset"},
+		{RESET_WDT, "watchdog reset"},
+		{RESET_CMD, "command reset"},
+		{RESET_SYNC, "synchronous reset"},
+		{RESET_SUSPEND, "suspend reset"},
+	} {
+		if uint8(r)&f.flag != 0 {
+			reasons = append(reasons, f.name)
+		}
+	}
+	if len(reasons) == 0 {
+		return fmt.Sprintf("reset 0x%02X", uint8(r))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// AsStartupReason decodes a MESG_STARTUP_MESG_ID message.
+func (m Message) AsStartupReason() (r StartupReason, ok bool) {
+	if m.Id != MESG_STARTUP_MESG_ID || len(m.Data) < 1 {
+		return r, false
+	}
+	return StartupReason(m.Data[0]), true
+}
+
+// OnReset registers fn to be called when the module restarts without having been told to with
+// ResetSystem, e.g. after a watchdog reset. The module has then lost its whole configuration
+// while the Ant still tracks it, so fn can restore it, typically from another goroutine with
+// ImportConfig(ctx, ExportConfig()). The returned function unregisters it.
+func (dev *Ant) OnReset(fn func(reason StartupReason)) (remove func()) {
+	return dev.OnMessage(MESG_STARTUP_MESG_ID, func(msg *Message) {
+		reason, ok := msg.AsStartupReason()
+
+		dev.mu.Lock()
+		unexpected := dev.unexpectedReset
+		dev.mu.Unlock()
+
+		if ok && unexpected {
+			fn(reason)
+		}
+	})
+}
+
+type messageHandler struct {
+	fn func(*Message)
+}
+
+// OnMessage registers fn to be called for every received message with the given ID.
+// Like channel event handlers it is called from the decoding goroutine.
+// The returned function unregisters the handler.
+func (dev *Ant) OnMessage(id uint8, fn func(*Message)) (remove func()) {
+	h := &messageHandler{fn}
+
+	dev.mu.Lock()
+	if dev.messageHandlers == nil {
+		dev.messageHandlers = make(map[uint8][]*messageHandler)
+	}
+	dev.messageHandlers[id] = append(dev.messageHandlers[id], h)
+	dev.mu.Unlock()
+
+	return func() {
+		dev.mu.Lock()
+		defer dev.mu.Unlock()
+
+		handlers := dev.messageHandlers[id]
+		for i := range handlers {
+			if handlers[i] == h {
+				dev.messageHandlers[id] = append(handlers[:i:i], handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// OnUnhandled sets fn to be called for every received message that no handler registered with
+// OnMessage or OnChannelEvent took, e.g. to discover unknown or proprietary messages.
+// The read channel and the Run handler don't count as handlers. A nil fn removes the tap.
+func (dev *Ant) OnUnhandled(fn func(*Message)) {
+	dev.mu.Lock()
+	dev.unhandled = fn
+	dev.mu.Unlock()
+}
+
+// dispatch calls the registered handlers for a message, falling back to the unhandled tap.
+func (dev *Ant) dispatch(msg *Message) {
+	dev.mu.Lock()
+	handlers := dev.messageHandlers[msg.Id]
+	unhandled := dev.unhandled
+	dev.mu.Unlock()
+
+	handled := len(handlers) > 0
+	for _, h := range handlers {
+		h.fn(msg)
+	}
+
+	if e, ok := msg.AsChannelEvent(); ok && dev.dispatchChannelEvent(e) {
+		handled = true
+	}
+
+	if !handled && unhandled != nil {
+		unhandled(msg)
+	}
+}
+
+func (dev *Ant) dispatchChannelEvent(e ChannelEvent) (handled bool) {
+	dev.mu.Lock()
+	handlers := dev.eventHandlers[e.Channel]
+	dev.mu.Unlock()
+
+	for _, h := range handlers {
+		h.fn(e)
+	}
+	return len(handlers) > 0
+}
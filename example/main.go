@@ -49,7 +49,7 @@ func main() {
 	time.Sleep(1000 * time.Millisecond)
 
 	Ant.SetNetworkKey(0, ant.AntPlusNetworkKey())
-	Ant.AssignChannel(0, ant.PARAMETER_RX_NOT_TX, 0)
+	Ant.AssignChannel(0, ant.ChannelTypeBidirectionalSlave, 0)
 	Ant.SetChannelId(0, 0, 120, 0)
 	Ant.SetChannelPeriod(0, 8070)
 	Ant.SetChannelRFFreq(0, 57)
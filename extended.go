@@ -0,0 +1,123 @@
+/*
+ * extended.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import "encoding/binary"
+
+// RSSIMeasurementDBm is the only RSSI measurement type defined so far, the value and
+// threshold are then signed dBm.
+const RSSIMeasurementDBm uint8 = 0x20
+
+// ExtendedInfo is the extension that follows the payload of data messages once extended messages
+// are enabled, see EnableExtendedMessages and SetLibConfig. Flags tells which fields are present,
+// using the ANT_LIB_CONFIG_MESG_OUT_INC_* bits.
+type ExtendedInfo struct {
+	Flags     uint8
+	ChannelID ChannelID
+
+	// RSSI fields, as reported by the module
+	MeasurementType uint8
+	RSSI            int8
+	Threshold       int8
+
+	// Timestamp of the reception, in 1/32768s counts
+	Timestamp uint16
+}
+
+// HasChannelID tells if the channel ID of the sender is present.
+func (e ExtendedInfo) HasChannelID() bool {
+	return e.Flags&ANT_LIB_CONFIG_MESG_OUT_INC_DEVICE_ID != 0
+}
+
+// HasRSSI tells if the RSSI fields are present.
+func (e ExtendedInfo) HasRSSI() bool {
+	return e.Flags&ANT_LIB_CONFIG_MESG_OUT_INC_RSSI != 0
+}
+
+// RSSIdBm returns the signal strength in dBm, ok is false if the RSSI isn't present
+// or is of a measurement type other than RSSIMeasurementDBm.
+func (e ExtendedInfo) RSSIdBm() (dBm int, ok bool) {
+	if !e.HasRSSI() || e.MeasurementType != RSSIMeasurementDBm {
+		return 0, false
+	}
+	return int(e.RSSI), true
+}
+
+// HasTimestamp tells if the timestamp is present.
+func (e ExtendedInfo) HasTimestamp() bool {
+	return e.Flags&ANT_LIB_CONFIG_MESG_OUT_INC_TIME_STAMP != 0
+}
+
+// ExtendedData parses the extension of a received broadcast, acknowledged or burst data message,
+// either in the flagged format (a flag byte after the payload) or in the legacy format of the
+// MESG_EXT_*_DATA_ID messages (the channel ID before the payload).
+// ok is false if the message carries no extension.
+func (m Message) ExtendedData() (e ExtendedInfo, ok bool) {
+	switch m.Id {
+	case MESG_EXT_BROADCAST_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID, MESG_EXT_BURST_DATA_ID:
+		// [channel, device number (2), device type, transmission type, payload (8)]
+		if len(m.Data) < 1+int(ANT_EXT_MESG_DEVICE_ID_FIELD_SIZE+ANT_STANDARD_DATA_PAYLOAD_SIZE) {
+			return e, false
+		}
+		e.Flags = ANT_LIB_CONFIG_MESG_OUT_INC_DEVICE_ID
+		e.ChannelID = channelIDFromBytes(m.Data[1:])
+		return e, true
+
+	case MESG_BROADCAST_DATA_ID, MESG_ACKNOWLEDGED_DATA_ID, MESG_BURST_DATA_ID:
+	default:
+		return e, false
+	}
+
+	// [channel, payload (8), flag, fields...]
+	flagIndex := 1 + int(ANT_STANDARD_DATA_PAYLOAD_SIZE)
+	if len(m.Data) <= flagIndex {
+		return e, false
+	}
+	e.Flags = m.Data[flagIndex]
+	fields := m.Data[flagIndex+1:]
+
+	// Fields come in the order of their flag bits, most significant first
+	if e.HasChannelID() {
+		if len(fields) < int(ANT_EXT_MESG_DEVICE_ID_FIELD_SIZE) {
+			return e, false
+		}
+		e.ChannelID = channelIDFromBytes(fields)
+		fields = fields[ANT_EXT_MESG_DEVICE_ID_FIELD_SIZE:]
+	}
+	if e.HasRSSI() {
+		if len(fields) < 3 {
+			return e, false
+		}
+		e.MeasurementType = fields[0]
+		e.RSSI = int8(fields[1])
+		e.Threshold = int8(fields[2])
+		fields = fields[3:]
+	}
+	if e.HasTimestamp() {
+		if len(fields) < 2 {
+			return e, false
+		}
+		e.Timestamp = binary.LittleEndian.Uint16(fields)
+	}
+
+	return e, true
+}
@@ -2,4 +2,7 @@ module github.com/purpl3F0x/go-ant
 
 go 1.17
 
-require github.com/google/gousb v1.1.2
+require (
+	github.com/google/gousb v1.1.2
+	go.uber.org/goleak v1.1.12
+)
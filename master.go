@@ -0,0 +1,111 @@
+/*
+ * master.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import "errors"
+
+// MasterConfig describes a transmit (master) channel.
+type MasterConfig struct {
+	Channel          uint8
+	NetworkNumber    uint8
+	DeviceNumber     uint16
+	DeviceType       uint8
+	TransmissionType uint8  // see MakeTransmissionType
+	Period           uint16 // in 1/32768s counts, see PeriodFromHz
+	RFFreq           uint8  // offset from 2400MHz, see RFFreqFromMHz
+}
+
+// OpenMasterChannel configures and opens a transmit channel, e.g. to emulate an ANT+ sensor.
+// next is called on every EVENT_TX to get the payload broadcast in the following period,
+// from the decoding goroutine, so it must not block. It stops being called once the channel closes.
+func (dev *Ant) OpenMasterChannel(cfg MasterConfig, next func() [8]byte) error {
+	if next == nil {
+		return errors.New("Master channel needs a payload function")
+	}
+
+	if err := cfg.checkID(); err != nil {
+		return err
+	}
+
+	channel := cfg.Channel
+
+	if err := dev.AssignChannel(channel, ChannelTypeBidirectionalMaster, cfg.NetworkNumber); err != nil {
+		return err
+	}
+	dev.SetChannelId(channel, cfg.DeviceNumber, cfg.DeviceType, cfg.TransmissionType)
+	dev.SetChannelPeriod(channel, cfg.Period)
+	dev.SetChannelRFFreq(channel, cfg.RFFreq)
+
+	var remove func()
+	remove = dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if !e.IsRFEvent() {
+			return
+		}
+
+		switch e.Code {
+		case EVENT_TX:
+			payload := next()
+			dev.SendBroadcastData(channel, payload[:])
+		case EVENT_CHANNEL_CLOSED:
+			remove()
+		}
+	})
+
+	dev.OpenChannel(channel)
+
+	// Fill the transmit buffer for the first period, later ones are sent on EVENT_TX
+	payload := next()
+	dev.SendBroadcastData(channel, payload[:])
+
+	return nil
+}
+
+// OpenAsyncTxChannel configures and opens an asynchronous transmit channel, e.g. for infrequent
+// status beacons: it has no period, each SendBroadcastData is transmitted once right away.
+// cfg.Period is ignored. It needs a module supporting extended assignment, see Capabilities.ExtendedAssign.
+func (dev *Ant) OpenAsyncTxChannel(cfg MasterConfig) error {
+	if c, ok := dev.Capabilities(); ok && !c.ExtendedAssign {
+		return ErrUnsupportedByDevice
+	}
+	if err := cfg.checkID(); err != nil {
+		return err
+	}
+
+	channel := cfg.Channel
+
+	if err := dev.AssignChannelExt(channel, ChannelTypeBidirectionalMaster, cfg.NetworkNumber, EXT_PARAM_ASYNC_TX); err != nil {
+		return err
+	}
+	dev.SetChannelId(channel, cfg.DeviceNumber, cfg.DeviceType, cfg.TransmissionType)
+	dev.SetChannelRFFreq(channel, cfg.RFFreq)
+	dev.OpenChannel(channel)
+
+	return nil
+}
+
+func (cfg MasterConfig) checkID() error {
+	// Wildcards are meaningless on a master, receivers could never match it
+	if cfg.DeviceNumber == 0 || cfg.DeviceType&^ANT_ID_DEVICE_TYPE_PAIRING_FLAG == 0 || cfg.TransmissionType == 0 {
+		return errors.New("Master channel needs a complete channel ID")
+	}
+	return nil
+}
@@ -22,9 +22,13 @@
 package ant
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus"
 )
 
 type Packet []byte
@@ -46,13 +50,93 @@ func (p Packet) String() string {
 	return b.String()
 }
 
+// MaxPayloadSize is the largest payload a frame can carry. The ANT serial framing has a single
+// length byte and no extended length encoding: even advanced burst packets and data messages with
+// every extended field stay well below it.
+const MaxPayloadSize = 0xFF
+
 type Message struct {
 	Id   byte
 	Data Packet
+
+	// Received is the time a received message was decoded, it is zero for outgoing messages
+	Received time.Time
 }
 
 func NewMessage(id byte, data Packet) *Message {
-	return &Message{id, data}
+	return &Message{Id: id, Data: data}
+}
+
+// ID returns the message ID, e.g. MESG_BROADCAST_DATA_ID or MESG_RESPONSE_EVENT_ID.
+func (m Message) ID() uint8 {
+	return m.Id
+}
+
+// Payload returns the message content, without the sync, length, ID and checksum bytes.
+// For channel messages the first byte is the channel number.
+func (m Message) Payload() []byte {
+	return m.Data
+}
+
+// Channel returns the channel number the message refers to, i.e. the first payload byte.
+// It returns 0 for messages with an empty payload; messages that are not channel specific
+// (e.g. MESG_STARTUP_MESG_ID or MESG_CAPABILITIES_ID) carry no meaningful channel.
+func (m Message) Channel() uint8 {
+	if len(m.Data) == 0 {
+		return 0
+	}
+
+	switch m.Id {
+	case MESG_BURST_DATA_ID, MESG_EXT_BURST_DATA_ID, MESG_ADV_BURST_DATA_ID:
+		// The upper bits carry the burst sequence number
+		return m.Data[0] & CHANNEL_NUMBER_MASK
+	}
+	return m.Data[0]
+}
+
+// AsBroadcastData returns the channel and the 8 byte payload of a broadcast data message,
+// with or without extended data.
+func (m Message) AsBroadcastData() (channel uint8, data [8]byte, ok bool) {
+	return m.asData(MESG_BROADCAST_DATA_ID, MESG_EXT_BROADCAST_DATA_ID)
+}
+
+// AsAcknowledgedData is AsBroadcastData for acknowledged data messages, which is how e.g.
+// control and FE-C commands arrive.
+func (m Message) AsAcknowledgedData() (channel uint8, data [8]byte, ok bool) {
+	return m.asData(MESG_ACKNOWLEDGED_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID)
+}
+
+func (m Message) asData(id uint8, extID uint8) (channel uint8, data [8]byte, ok bool) {
+	offset := 1
+	switch m.Id {
+	case id:
+	case extID:
+		// The legacy extended messages have the channel ID before the payload
+		offset += int(ANT_EXT_MESG_DEVICE_ID_FIELD_SIZE)
+	default:
+		return 0, data, false
+	}
+
+	if len(m.Data) < offset+len(data) {
+		return 0, data, false
+	}
+	copy(data[:], m.Data[offset:])
+	return m.Data[0], data, true
+}
+
+// isChannelMessage tells if the first payload byte of the message is a channel number.
+func (m Message) isChannelMessage() bool {
+	return isChannelMessageID(m.Id) && len(m.Data) > 0
+}
+
+func isChannelMessageID(id uint8) bool {
+	switch id {
+	case MESG_BROADCAST_DATA_ID, MESG_ACKNOWLEDGED_DATA_ID, MESG_BURST_DATA_ID,
+		MESG_EXT_BROADCAST_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID, MESG_EXT_BURST_DATA_ID,
+		MESG_ADV_BURST_DATA_ID, MESG_RESPONSE_EVENT_ID, MESG_CHANNEL_ID_ID, MESG_CHANNEL_STATUS_ID:
+		return true
+	}
+	return false
 }
 
 func (m Message) length() int {
@@ -64,6 +148,57 @@ func (m Message) String() string {
 
 }
 
+type messageJSON struct {
+	Name      string
+	ID        uint8
+	Channel   *uint8      `json:",omitempty"`
+	Page      *uint8      `json:",omitempty"` // First payload byte of data messages
+	Payload   string      // Hex
+	Event     string      `json:",omitempty"` // Response or event code of response events
+	ChannelID *ChannelID  `json:",omitempty"` // From the extended data
+	Decoded   interface{} `json:",omitempty"` // By the antplus decoder of the device type
+	Received  *time.Time  `json:",omitempty"`
+}
+
+// MarshalJSON describes the message for tooling, e.g. to pipe newline-delimited JSON into jq:
+// its name, channel, page and payload in hex, and for data messages carrying the channel ID of
+// the sender the data page decoded by the antplus package, if a decoder for it was registered.
+func (m Message) MarshalJSON() ([]byte, error) {
+	j := messageJSON{
+		Name:    MessageName(m.Id),
+		ID:      m.Id,
+		Payload: fmt.Sprintf("%X", []byte(m.Data)),
+	}
+
+	if m.isChannelMessage() {
+		channel := m.Channel()
+		j.Channel = &channel
+	}
+	if !m.Received.IsZero() {
+		j.Received = &m.Received
+	}
+	if e, ok := m.AsChannelEvent(); ok {
+		j.Event = ResponseCodeName(e.Code)
+	}
+
+	_, payload, ok := m.AsBroadcastData()
+	if !ok {
+		_, payload, ok = m.AsAcknowledgedData()
+	}
+	if ok {
+		j.Page = &payload[0]
+
+		if e, ok := m.ExtendedData(); ok && e.HasChannelID() {
+			j.ChannelID = &e.ChannelID
+			if decoded, err := antplus.Decode(e.ChannelID.DeviceType, payload); err == nil {
+				j.Decoded = decoded
+			}
+		}
+	}
+
+	return json.Marshal(j)
+}
+
 func (m Message) Checksum() (checksum byte) {
 	n := len(m.Data)
 	checksum = MESG_TX_SYNC ^ byte(n) ^ m.Id
@@ -73,7 +208,30 @@ func (m Message) Checksum() (checksum byte) {
 	return checksum
 }
 
+// Checksum computes the checksum of a raw frame: the XOR of all its bytes except the last,
+// which is where the checksum goes.
+func Checksum(frame []byte) (checksum byte) {
+	if len(frame) == 0 {
+		return 0
+	}
+
+	for _, b := range frame[:len(frame)-1] {
+		checksum ^= b
+	}
+	return checksum
+}
+
+// VerifyChecksum tells if the last byte of a raw frame is its correct checksum.
+func VerifyChecksum(frame []byte) bool {
+	return len(frame) > 0 && frame[len(frame)-1] == Checksum(frame)
+}
+
+// Encode returns the frame of the message. It panics if the payload is longer than MaxPayloadSize.
 func (m Message) Encode() Packet {
+	if len(m.Data) > MaxPayloadSize {
+		panic(fmt.Sprintf("Payload length should be at most %d not %d", MaxPayloadSize, len(m.Data)))
+	}
+
 	rawLen := m.length()
 	msgLen := len(m.Data)
 	raw := make(Packet, rawLen)
@@ -82,13 +240,7 @@ func (m Message) Encode() Packet {
 	raw[1] = byte(msgLen)
 	raw[2] = m.Id
 	copy(raw[MESG_DATA_OFFSET:], m.Data)
-
-	checksum := MESG_TX_SYNC ^ byte(msgLen) ^ m.Id
-
-	for n := 2; n < msgLen; n++ {
-		checksum ^= m.Data[n]
-	}
-	raw[rawLen-1] = checksum
+	raw[rawLen-1] = Checksum(raw)
 	// raw[rawLen] = 0
 	// raw[rawLen+1] = 0
 
@@ -0,0 +1,150 @@
+/*
+ * message_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMessageAccessors(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *Message
+		id      uint8
+		payload []byte
+		channel uint8
+	}{
+		{"broadcast", NewMessage(MESG_BROADCAST_DATA_ID, Packet{3, 1, 2, 3, 4, 5, 6, 7, 8}), MESG_BROADCAST_DATA_ID, []byte{3, 1, 2, 3, 4, 5, 6, 7, 8}, 3},
+		{"response event", NewMessage(MESG_RESPONSE_EVENT_ID, Packet{2, MESG_OPEN_CHANNEL_ID, RESPONSE_NO_ERROR}), MESG_RESPONSE_EVENT_ID, []byte{2, MESG_OPEN_CHANNEL_ID, RESPONSE_NO_ERROR}, 2},
+		{"burst with sequence", NewMessage(MESG_BURST_DATA_ID, Packet{0xE5, 0, 0, 0, 0, 0, 0, 0, 0}), MESG_BURST_DATA_ID, []byte{0xE5, 0, 0, 0, 0, 0, 0, 0, 0}, 5},
+		{"startup", NewMessage(MESG_STARTUP_MESG_ID, Packet{RESET_POR}), MESG_STARTUP_MESG_ID, []byte{RESET_POR}, 0},
+		{"empty payload", NewMessage(MESG_REQUEST_ID, nil), MESG_REQUEST_ID, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.ID(); got != tt.id {
+				t.Errorf("ID() = 0x%02X, want 0x%02X", got, tt.id)
+			}
+			if got := tt.msg.Payload(); !bytes.Equal(got, tt.payload) {
+				t.Errorf("Payload() = %v, want %v", got, tt.payload)
+			}
+			if got := tt.msg.Channel(); got != tt.channel {
+				t.Errorf("Channel() = %d, want %d", got, tt.channel)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	// Frames from the examples of the ANT Message Protocol and Usage document
+	frames := []struct {
+		name  string
+		frame []byte
+	}{
+		{"reset system", []byte{0xA4, 0x01, 0x4A, 0x00, 0xEF}},
+		{"request capabilities", []byte{0xA4, 0x02, 0x4D, 0x00, 0x54, 0xBF}},
+		{"assign channel", []byte{0xA4, 0x03, 0x42, 0x00, 0x00, 0x00, 0xE5}},
+		{"open channel", []byte{0xA4, 0x01, 0x4B, 0x00, 0xEE}},
+		{"startup message", []byte{0xA4, 0x01, 0x6F, 0x20, 0xEA}},
+	}
+
+	for _, tt := range frames {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.frame[len(tt.frame)-1]
+			if got := Checksum(tt.frame); got != want {
+				t.Errorf("Checksum = 0x%02X, want 0x%02X", got, want)
+			}
+			if !VerifyChecksum(tt.frame) {
+				t.Error("VerifyChecksum = false for a good frame")
+			}
+
+			corrupt := append([]byte(nil), tt.frame...)
+			corrupt[len(corrupt)-1] ^= 0xFF
+			if VerifyChecksum(corrupt) {
+				t.Error("VerifyChecksum = true for a corrupt frame")
+			}
+
+			msg, err := Decode(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msg.Checksum(); got != want {
+				t.Errorf("Message.Checksum = 0x%02X, want 0x%02X", got, want)
+			}
+		})
+	}
+
+	if VerifyChecksum(nil) {
+		t.Error("VerifyChecksum = true for an empty frame")
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	msg := NewMessage(MESG_BROADCAST_DATA_ID, Packet{0, 0x04, 0, 0, 0, 0x10, 0x20, 5, 72})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = msg.Encode()
+	}
+}
+
+func TestMaxLengthFrame(t *testing.T) {
+	payload := make(Packet, MaxPayloadSize)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	frame := NewMessage(MESG_ADV_BURST_DATA_ID, payload).Encode()
+	if len(frame) != MaxPayloadSize+MESG_FRAME_SIZE {
+		t.Fatalf("got a %d byte frame, want %d", len(frame), MaxPayloadSize+MESG_FRAME_SIZE)
+	}
+	if frame[MESG_SIZE_OFFSET] != 0xFF {
+		t.Errorf("got length byte 0x%02X, want 0xFF", frame[MESG_SIZE_OFFSET])
+	}
+
+	var d Decoder
+	_, _ = d.Write(frame)
+	msg, err := d.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil || msg.Id != MESG_ADV_BURST_DATA_ID || !bytes.Equal(msg.Data, payload) {
+		t.Fatalf("got %v", msg)
+	}
+
+	// One more byte doesn't fit the length byte
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Encode didn't panic on an oversized payload")
+			}
+		}()
+		NewMessage(MESG_ADV_BURST_DATA_ID, make(Packet, MaxPayloadSize+1)).Encode()
+	}()
+
+	dev := startTestAnt(t, &testDriver{})
+	if err := dev.WriteMessage(MESG_ADV_BURST_DATA_ID, make(Packet, MaxPayloadSize+1)); err == nil {
+		t.Error("WriteMessage accepted an oversized payload")
+	}
+}
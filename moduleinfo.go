@@ -0,0 +1,117 @@
+/*
+ * moduleinfo.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// ModuleInfo is what the module reports about itself: the version and serial number, the
+// diagnostics every module can be asked for. The ANT message protocol has no documented live
+// reading of the supply voltage or temperature; MESG_GET_TEMP_CAL_ID reads back the temperature
+// calibration the module was programmed with (see MESG_OVERWRITE_TEMP_CAL_ID), not its current
+// temperature, so it isn't part of ModuleInfo.
+type ModuleInfo struct {
+	Version      string // e.g. "AJK1.04RAF"
+	SerialNumber uint32 // 0 if the module doesn't report one
+}
+
+// ModuleInfo requests the version and, if the capabilities say it has one, the serial number
+// of the module. The capabilities are requested first if they aren't known yet.
+func (dev *Ant) ModuleInfo(ctx context.Context) (info ModuleInfo, err error) {
+	c, ok := dev.Capabilities()
+	if !ok {
+		msg, err := dev.RequestMessageSync(ctx, 0, MESG_CAPABILITIES_ID)
+		if err != nil {
+			return info, err
+		}
+		c, _ = msg.AsCapabilities()
+	}
+
+	msg, err := dev.RequestMessageSync(ctx, 0, MESG_VERSION_ID)
+	if err != nil {
+		return info, err
+	}
+	version := msg.Data
+	if i := bytes.IndexByte(version, 0); i >= 0 {
+		version = version[:i]
+	}
+	info.Version = string(version)
+
+	if c.AdvancedOptions&CAPABILITIES_SERIAL_NUMBER_ENABLED != 0 {
+		msg, err := dev.RequestMessageSync(ctx, 0, MESG_GET_SERIAL_NUM_ID)
+		if err != nil {
+			return info, err
+		}
+		if len(msg.Data) >= 4 {
+			info.SerialNumber = binary.LittleEndian.Uint32(msg.Data)
+		}
+	}
+
+	return info, nil
+}
+
+// SetUSBDescriptorString writes a USB descriptor string of the module, one of
+// USB_DESCRIPTOR_MANUFACTURER_STRING, USB_DESCRIPTOR_DEVICE_STRING and USB_DESCRIPTOR_SERIAL_STRING,
+// and waits for the module to accept it. Modules that don't support it reject the command with a
+// ResponseError, USB_STRING_WRITE_FAIL if the write failed. The host only sees the new string
+// once the module has been reset and enumerated again. It panics on any other descriptor.
+func (dev *Ant) SetUSBDescriptorString(ctx context.Context, descriptor uint8, s string) error {
+	switch descriptor {
+	case USB_DESCRIPTOR_MANUFACTURER_STRING, USB_DESCRIPTOR_DEVICE_STRING, USB_DESCRIPTOR_SERIAL_STRING:
+	default:
+		panic(fmt.Sprint("Invalid USB descriptor string number ", descriptor))
+	}
+
+	payload := append(append(Packet{descriptor}, s...), 0)
+	return dev.commandSync(ctx, PriorityConfig, 0, NewMessage(MESG_SET_USB_INFO_ID, payload))
+}
+
+// SetLabel stores label as the USB device string of the module, which survives re-enumeration
+// and can be read back with Label, to identify a module among identical ones.
+func (dev *Ant) SetLabel(ctx context.Context, label string) error {
+	return dev.SetUSBDescriptorString(ctx, USB_DESCRIPTOR_DEVICE_STRING, label)
+}
+
+// Label returns the label of the module: the USB device string if the driver can read it
+// (see UsbDevice.Label), otherwise the serial number of the module in decimal. Without either
+// it returns ErrUnsupportedByDevice.
+func (dev *Ant) Label(ctx context.Context) (string, error) {
+	if d, ok := dev.driver.(interface{ Label() (string, error) }); ok {
+		if label, err := d.Label(); err == nil && label != "" {
+			return label, nil
+		}
+	}
+
+	info, err := dev.ModuleInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.SerialNumber == 0 {
+		return "", ErrUnsupportedByDevice
+	}
+	return strconv.FormatUint(uint64(info.SerialNumber), 10), nil
+}
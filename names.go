@@ -0,0 +1,120 @@
+/*
+ * names.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import "fmt"
+
+var messageNames = map[uint8]string{
+	MESG_EVENT_ID:                  "EVENT",
+	MESG_VERSION_ID:                "VERSION",
+	MESG_RESPONSE_EVENT_ID:         "RESPONSE_EVENT",
+	MESG_UNASSIGN_CHANNEL_ID:       "UNASSIGN_CHANNEL",
+	MESG_ASSIGN_CHANNEL_ID:         "ASSIGN_CHANNEL",
+	MESG_CHANNEL_MESG_PERIOD_ID:    "CHANNEL_MESG_PERIOD",
+	MESG_CHANNEL_SEARCH_TIMEOUT_ID: "CHANNEL_SEARCH_TIMEOUT",
+	MESG_CHANNEL_RADIO_FREQ_ID:     "CHANNEL_RADIO_FREQ",
+	MESG_NETWORK_KEY_ID:            "NETWORK_KEY",
+	MESG_RADIO_TX_POWER_ID:         "RADIO_TX_POWER",
+	MESG_SEARCH_WAVEFORM_ID:        "SEARCH_WAVEFORM",
+	MESG_SYSTEM_RESET_ID:           "SYSTEM_RESET",
+	MESG_OPEN_CHANNEL_ID:           "OPEN_CHANNEL",
+	MESG_CLOSE_CHANNEL_ID:          "CLOSE_CHANNEL",
+	MESG_REQUEST_ID:                "REQUEST",
+	MESG_BROADCAST_DATA_ID:         "BROADCAST_DATA",
+	MESG_ACKNOWLEDGED_DATA_ID:      "ACKNOWLEDGED_DATA",
+	MESG_BURST_DATA_ID:             "BURST_DATA",
+	MESG_CHANNEL_ID_ID:             "CHANNEL_ID",
+	MESG_CHANNEL_STATUS_ID:         "CHANNEL_STATUS",
+	MESG_CAPABILITIES_ID:           "CAPABILITIES",
+	MESG_ID_LIST_ADD_ID:            "ID_LIST_ADD",
+	MESG_ID_LIST_CONFIG_ID:         "ID_LIST_CONFIG",
+	MESG_OPEN_RX_SCAN_ID:           "OPEN_RX_SCAN",
+	MESG_EXT_BROADCAST_DATA_ID:     "EXT_BROADCAST_DATA",
+	MESG_EXT_ACKNOWLEDGED_DATA_ID:  "EXT_ACKNOWLEDGED_DATA",
+	MESG_EXT_BURST_DATA_ID:         "EXT_BURST_DATA",
+	MESG_CHANNEL_RADIO_TX_POWER_ID: "CHANNEL_RADIO_TX_POWER",
+	MESG_GET_SERIAL_NUM_ID:         "GET_SERIAL_NUM",
+	MESG_SET_LP_SEARCH_TIMEOUT_ID:  "SET_LP_SEARCH_TIMEOUT",
+	MESG_RX_EXT_MESGS_ENABLE_ID:    "RX_EXT_MESGS_ENABLE",
+	MESG_ANTLIB_CONFIG_ID:          "ANTLIB_CONFIG",
+	MESG_STARTUP_MESG_ID:           "STARTUP_MESG",
+	MESG_AUTO_FREQ_CONFIG_ID:       "AUTO_FREQ_CONFIG",
+	MESG_PROX_SEARCH_CONFIG_ID:     "PROX_SEARCH_CONFIG",
+	MESG_ADV_BURST_DATA_ID:         "ADV_BURST_DATA",
+	MESG_EVENT_BUFFERING_CONFIG_ID: "EVENT_BUFFERING_CONFIG",
+	MESG_CONFIG_ADV_BURST_ID:       "CONFIG_ADV_BURST",
+	MESG_EVENT_FILTER_CONFIG_ID:    "EVENT_FILTER_CONFIG",
+	MESG_ENCRYPT_ENABLE_ID:         "ENCRYPT_ENABLE",
+	MESG_SET_SHARED_ADDRESS_ID:     "SET_SHARED_ADDRESS",
+	MESG_SET_USB_INFO_ID:           "SET_USB_INFO",
+}
+
+var responseCodeNames = map[uint8]string{
+	RESPONSE_NO_ERROR:              "RESPONSE_NO_ERROR",
+	EVENT_RX_SEARCH_TIMEOUT:        "EVENT_RX_SEARCH_TIMEOUT",
+	EVENT_RX_FAIL:                  "EVENT_RX_FAIL",
+	EVENT_TX:                       "EVENT_TX",
+	EVENT_TRANSFER_RX_FAILED:       "EVENT_TRANSFER_RX_FAILED",
+	EVENT_TRANSFER_TX_COMPLETED:    "EVENT_TRANSFER_TX_COMPLETED",
+	EVENT_TRANSFER_TX_FAILED:       "EVENT_TRANSFER_TX_FAILED",
+	EVENT_CHANNEL_CLOSED:           "EVENT_CHANNEL_CLOSED",
+	EVENT_RX_FAIL_GO_TO_SEARCH:     "EVENT_RX_FAIL_GO_TO_SEARCH",
+	EVENT_CHANNEL_COLLISION:        "EVENT_CHANNEL_COLLISION",
+	EVENT_TRANSFER_TX_START:        "EVENT_TRANSFER_TX_START",
+	EVENT_CHANNEL_ACTIVE:           "EVENT_CHANNEL_ACTIVE",
+	CHANNEL_IN_WRONG_STATE:         "CHANNEL_IN_WRONG_STATE",
+	CHANNEL_NOT_OPENED:             "CHANNEL_NOT_OPENED",
+	CHANNEL_ID_NOT_SET:             "CHANNEL_ID_NOT_SET",
+	CLOSE_ALL_CHANNELS:             "CLOSE_ALL_CHANNELS",
+	TRANSFER_IN_PROGRESS:           "TRANSFER_IN_PROGRESS",
+	TRANSFER_SEQUENCE_NUMBER_ERROR: "TRANSFER_SEQUENCE_NUMBER_ERROR",
+	TRANSFER_IN_ERROR:              "TRANSFER_IN_ERROR",
+	TRANSFER_BUSY:                  "TRANSFER_BUSY",
+	INVALID_MESSAGE_CRC:            "INVALID_MESSAGE_CRC",
+	MESSAGE_SIZE_EXCEEDS_LIMIT:     "MESSAGE_SIZE_EXCEEDS_LIMIT",
+	INVALID_MESSAGE:                "INVALID_MESSAGE",
+	INVALID_NETWORK_NUMBER:         "INVALID_NETWORK_NUMBER",
+	INVALID_LIST_ID:                "INVALID_LIST_ID",
+	INVALID_SCAN_TX_CHANNEL:        "INVALID_SCAN_TX_CHANNEL",
+	INVALID_PARAMETER_PROVIDED:     "INVALID_PARAMETER_PROVIDED",
+	EVENT_SERIAL_QUE_OVERFLOW:      "EVENT_SERIAL_QUE_OVERFLOW",
+	EVENT_QUE_OVERFLOW:             "EVENT_QUE_OVERFLOW",
+	USB_STRING_WRITE_FAIL:          "USB_STRING_WRITE_FAIL",
+}
+
+// MessageName returns the name of a message ID without its MESG_ prefix and _ID suffix,
+// e.g. "ASSIGN_CHANNEL", or its value in hex for the less common ones.
+func MessageName(id uint8) string {
+	if name, ok := messageNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", id)
+}
+
+// ResponseCodeName returns the name of a response or event code, e.g. "CHANNEL_IN_WRONG_STATE",
+// or its value in hex for the less common ones.
+func ResponseCodeName(code uint8) string {
+	if name, ok := responseCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02X", code)
+}
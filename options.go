@@ -0,0 +1,126 @@
+/*
+ * options.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"io"
+	"log"
+	"time"
+)
+
+// Option configures an Ant at construction, see MakeAnt.
+type Option func(ant *Ant)
+
+// WithBurstCoalescing packs the packets of a burst transfer into as few driver writes as the
+// driver's buffer size allows, instead of one write per packet. With USB drivers, where every
+// write is a separate bulk transfer, this considerably speeds up bursts.
+func WithBurstCoalescing() Option {
+	return func(ant *Ant) {
+		ant.coalesceBursts = true
+	}
+}
+
+// WithDedup drops broadcast data on the channel whose payload is identical to the previous
+// broadcast, so the read channel and Run handler only see changes. It can be given for several channels.
+func WithDedup(channel uint8) Option {
+	return func(ant *Ant) {
+		if ant.dedup == nil {
+			ant.dedup = make(map[uint8]*dedupState)
+		}
+		ant.dedup[channel] = &dedupState{}
+	}
+}
+
+// OverflowPolicy decides what happens to a received message when the read channel is full.
+type OverflowPolicy uint8
+
+const (
+	DropNewest OverflowPolicy = iota // Discard the new message (the default)
+	DropOldest                       // Discard the oldest queued message to make room for the new one
+	Block                            // Wait for the reader, holding back decoding
+)
+
+// WithReadBuffer replaces the read channel given to MakeAnt with one buffering size messages,
+// available from Messages, and sets what to do when it fills up.
+func WithReadBuffer(size int, policy OverflowPolicy) Option {
+	return func(ant *Ant) {
+		ant.read = make(chan *Message, size)
+		ant.overflow = policy
+	}
+}
+
+// WithLogger sets where the Ant, and a driver with a SetLogger method, log to.
+// A nil logger discards everything. By default the standard logger is used.
+func WithLogger(logger *log.Logger) Option {
+	return func(ant *Ant) {
+		if logger == nil {
+			logger = log.New(io.Discard, "", 0)
+		}
+		ant.logger = logger
+	}
+}
+
+// WithSilent turns off all logging, same as WithLogger(nil).
+func WithSilent() Option {
+	return WithLogger(nil)
+}
+
+// WithReadBackoff sets how long the read loop waits before polling the driver again after a
+// read returned no data, 1ms by default. Drivers whose Read returns immediately when idle
+// can use a longer backoff to save CPU, at the cost of latency. Reads are retried right away
+// as long as they return data.
+func WithReadBackoff(d time.Duration) Option {
+	return func(ant *Ant) {
+		ant.readBackoff = d
+	}
+}
+
+// WithTraceBuffer keeps the last n frames written and read, corrupt ones included, so they can be
+// dumped with Trace when something goes wrong without logging all the traffic. Off by default.
+func WithTraceBuffer(n int) Option {
+	return func(ant *Ant) {
+		if n > 0 {
+			ant.trace = &traceBuffer{frames: make([]TracedFrame, n)}
+		}
+	}
+}
+
+// WithAutoReopen reopens the channel whenever the module closes it by itself, which it does after
+// a search timeout, e.g. when the sensor went out of range, so the channel keeps searching until
+// it comes back. Channels closed with CloseChannel stay closed. It can be given for several channels.
+func WithAutoReopen(channel uint8) Option {
+	return func(ant *Ant) {
+		if ant.autoReopen == nil {
+			ant.autoReopen = make(map[uint8]bool)
+		}
+		ant.autoReopen[channel] = true
+	}
+}
+
+// WithResponseChecking logs a warning, with the command and response code names, for every
+// command the module rejects with a response code other than RESPONSE_NO_ERROR. Most commands
+// are sent without waiting for their response, so this makes rejections visible while developing.
+func WithResponseChecking(enable bool) Option {
+	return func(ant *Ant) {
+		ant.checkResponses = enable
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * profile.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus"
+)
+
+// Profile describes a kind of device and the channel parameters used to reach it,
+// e.g. an ANT+ heart rate monitor.
+type Profile struct {
+	Name       string
+	DeviceType uint8  // without the pairing bit
+	Period     uint16 // in 1/32768s counts, see PeriodFromHz
+	RFFreq     uint8  // offset from 2400MHz, see RFFreqFromMHz
+
+	// Decode turns a broadcast payload into a sample, e.g. an hrm.Data, ok is false for
+	// payloads that don't carry one. It is optional, only ReadProfile needs it.
+	Decode func(payload [8]byte) (sample interface{}, ok bool)
+}
+
+// The standard ANT+ profiles. Their Decode uses the antplus registry, so it only decodes pages
+// once the matching profile package (e.g. antplus/hrm) is imported.
+var (
+	ProfileHeartRate            = antPlusProfile("Heart Rate", 120, 8070)
+	ProfileBikeSpeedCadence     = antPlusProfile("Bike Speed and Cadence", 121, 8086)
+	ProfileBikeCadence          = antPlusProfile("Bike Cadence", 122, 8102)
+	ProfileBikeSpeed            = antPlusProfile("Bike Speed", 123, 8118)
+	ProfileBikePower            = antPlusProfile("Bike Power", 11, 8182)
+	ProfileFitnessEquipment     = antPlusProfile("Fitness Equipment", 17, 8192)
+	ProfileStrideSpeedDistance  = antPlusProfile("Stride Based Speed and Distance", 124, 8134)
+	ProfileControls             = antPlusProfile("Controls", 16, 8192)
+	ProfileBloodPressure        = antPlusProfile("Blood Pressure", 18, 8192)
+	ProfileGeocache             = antPlusProfile("Geocache", 19, 8192)
+	ProfileLightElectricVehicle = antPlusProfile("Light Electric Vehicle", 20, 8192)
+	ProfileEnvironment          = antPlusProfile("Environment", 25, 8192)
+	ProfileRunningDynamics      = antPlusProfile("Running Dynamics", 30, 8192)
+	ProfileMuscleOxygen         = antPlusProfile("Muscle Oxygen", 31, 8192)
+	ProfileShifting             = antPlusProfile("Shifting", 34, 8192)
+	ProfileBikeLights           = antPlusProfile("Bike Lights", 35, 8192)
+	ProfileBikeRadar            = antPlusProfile("Bike Radar", 40, 8192)
+	ProfileTirePressure         = antPlusProfile("Tire Pressure Monitor", 48, 8192)
+	ProfileWeightScale          = antPlusProfile("Weight Scale", 119, 8192)
+)
+
+var antPlusProfiles = map[uint8]Profile{}
+
+func antPlusProfile(name string, deviceType uint8, period uint16) Profile {
+	p := Profile{
+		Name:       name,
+		DeviceType: deviceType,
+		Period:     period,
+		RFFreq:     RFFreqANTPlus,
+		Decode: func(payload [8]byte) (interface{}, bool) {
+			sample, err := antplus.Decode(deviceType, payload)
+			return sample, err == nil
+		},
+	}
+	antPlusProfiles[deviceType] = p
+	return p
+}
+
+// ProfileFromDeviceType returns the standard ANT+ profile of a device type, e.g. ProfileHeartRate
+// for 120. The pairing bit is ignored. The way back is the DeviceType of the profile.
+func ProfileFromDeviceType(deviceType uint8) (Profile, bool) {
+	p, ok := antPlusProfiles[deviceType&^ANT_ID_DEVICE_TYPE_PAIRING_FLAG]
+	return p, ok
+}
+
+// closeTimeout bounds how long one-shot helpers wait for their channel to close.
+const closeTimeout = time.Second
+
+// defaultMaxChannels is assumed until the module reports its capabilities.
+const defaultMaxChannels = 8
+
+// ReadProfile opens a channel to the device (0 for any device of the profile's type), waits for
+// the first sample profile.Decode accepts, closes the channel and returns the sample.
+// It uses the first unassigned channel on network 0, where the ANT+ network key must have been set.
+// ErrSearchTimeout is returned if the search times out without finding the device.
+func (dev *Ant) ReadProfile(ctx context.Context, profile Profile, deviceNum uint16) (interface{}, error) {
+	if profile.Decode == nil {
+		return nil, errors.New("Profile has no decoder")
+	}
+
+	channel, release, err := dev.freeChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	samples := make(chan interface{}, 1)
+	timeout := make(chan struct{}, 1)
+	closed := make(chan struct{}, 1)
+
+	broadcast := func(msg *Message) {
+		ch, payload, ok := msg.AsBroadcastData()
+		if !ok || ch != channel {
+			return
+		}
+
+		if sample, ok := profile.Decode(payload); ok {
+			select {
+			case samples <- sample:
+			default:
+			}
+		}
+	}
+	// Flagged extended data comes as plain broadcasts, the legacy extended messages have their own ID
+	defer dev.OnMessage(MESG_BROADCAST_DATA_ID, broadcast)()
+	defer dev.OnMessage(MESG_EXT_BROADCAST_DATA_ID, broadcast)()
+	defer dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		var signal chan struct{}
+		switch {
+		case e.IsRFEvent() && e.Code == EVENT_RX_SEARCH_TIMEOUT:
+			signal = timeout
+		case e.IsRFEvent() && e.Code == EVENT_CHANNEL_CLOSED:
+			signal = closed
+		default:
+			return
+		}
+
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	})()
+
+	if err := dev.AssignChannel(channel, ChannelTypeBidirectionalSlave, 0); err != nil {
+		return nil, err
+	}
+	dev.SetChannelId(channel, deviceNum, profile.DeviceType, 0)
+	dev.SetChannelPeriod(channel, profile.Period)
+	dev.SetChannelRFFreq(channel, profile.RFFreq)
+	dev.OpenChannel(channel)
+
+	var sample interface{}
+	select {
+	case sample = <-samples:
+	case <-timeout:
+		err = ErrSearchTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// The channel can only be unassigned once closed
+	closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	if err == ErrSearchTimeout {
+		// The module closes the channel by itself after a search timeout
+		select {
+		case <-closed:
+		case <-closeCtx.Done():
+		}
+	} else if closeErr := dev.CloseChannelSync(closeCtx, channel); err == nil {
+		err = closeErr
+	}
+	dev.UnAssignChannel(channel)
+
+	if err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// freeChannel reserves the lowest channel that is neither assigned nor reserved, until release
+// is called, so that helpers running concurrently don't pick the same one.
+func (dev *Ant) freeChannel() (channel uint8, release func(), err error) {
+	maxChannels := uint8(defaultMaxChannels)
+	if c, ok := dev.Capabilities(); ok {
+		maxChannels = c.MaxChannels
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	for channel = 0; channel < maxChannels; channel++ {
+		if release, err = dev.reserveChannelLocked(channel); err == nil {
+			return channel, release, nil
+		}
+	}
+	return 0, nil, ErrNoFreeChannel
+}
+
+// reserveChannel reserves the channel until release is called, or returns ErrChannelInUse if
+// it is assigned or reserved already.
+func (dev *Ant) reserveChannel(channel uint8) (release func(), err error) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	return dev.reserveChannelLocked(channel)
+}
+
+// reserveChannelLocked is reserveChannel with dev.mu held.
+func (dev *Ant) reserveChannelLocked(channel uint8) (release func(), err error) {
+	if _, assigned := dev.channels[channel]; assigned || dev.reserved[channel] {
+		return nil, fmt.Errorf("%w: channel %d", ErrChannelInUse, channel)
+	}
+
+	if dev.reserved == nil {
+		dev.reserved = make(map[uint8]bool)
+	}
+	dev.reserved[channel] = true
+
+	return func() {
+		dev.mu.Lock()
+		delete(dev.reserved, channel)
+		dev.mu.Unlock()
+	}, nil
+}
@@ -0,0 +1,138 @@
+/*
+ * profile_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus/hrm"
+)
+
+func TestReadProfileSearchTimeout(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := dev.ReadProfile(context.Background(), ProfileHeartRate, 0)
+		result <- err
+	}()
+
+	drv.waitWritten(t, MESG_OPEN_CHANNEL_ID)
+	drv.feed(NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, MESG_EVENT_ID, EVENT_RX_SEARCH_TIMEOUT}))
+
+	// The module still has to report the channel closed
+	time.Sleep(20 * time.Millisecond)
+	for _, msg := range drv.written(t) {
+		if msg.Id == MESG_UNASSIGN_CHANNEL_ID {
+			t.Fatal("channel unassigned before EVENT_CHANNEL_CLOSED")
+		}
+	}
+
+	drv.feed(NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, MESG_EVENT_ID, EVENT_CHANNEL_CLOSED}))
+	select {
+	case err := <-result:
+		if err != ErrSearchTimeout {
+			t.Errorf("got %v, want %v", err, ErrSearchTimeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReadProfile did not return")
+	}
+	drv.waitWritten(t, MESG_UNASSIGN_CHANNEL_ID)
+}
+
+func TestReadProfileExtendedMessages(t *testing.T) {
+	payload := Packet{4, 0, 0, 0, 0x10, 0x20, 5, 72}
+	tests := []struct {
+		name string
+		msg  *Message
+	}{
+		{"legacy", NewMessage(MESG_EXT_BROADCAST_DATA_ID, append(Packet{0, 0x34, 0x12, 120, 1}, payload...))},
+		{"flagged", NewMessage(MESG_BROADCAST_DATA_ID, append(append(Packet{0}, payload...), ANT_EXT_MESG_BITFIELD_DEVICE_ID, 0x34, 0x12, 120, 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drv := &testDriver{}
+			dev := startTestAnt(t, drv)
+
+			type result struct {
+				sample interface{}
+				err    error
+			}
+			results := make(chan result, 1)
+			go func() {
+				sample, err := dev.ReadProfile(context.Background(), ProfileHeartRate, 0)
+				results <- result{sample, err}
+			}()
+
+			drv.waitWritten(t, MESG_OPEN_CHANNEL_ID)
+			drv.feed(tt.msg)
+			drv.waitWritten(t, MESG_CLOSE_CHANNEL_ID)
+			drv.feed(NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, MESG_EVENT_ID, EVENT_CHANNEL_CLOSED}))
+
+			select {
+			case r := <-results:
+				if r.err != nil {
+					t.Fatal(r.err)
+				}
+				if data, ok := r.sample.(hrm.Data); !ok || data.ComputedHeartRate != 72 {
+					t.Errorf("got %+v, want 72 bpm", r.sample)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("ReadProfile did not return")
+			}
+		})
+	}
+}
+
+func TestFreeChannelReserves(t *testing.T) {
+	dev := MakeAnt(&testDriver{}, nil, WithSilent())
+
+	first, releaseFirst, err := dev.freeChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, releaseSecond, err := dev.freeChannel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSecond()
+	if first != 0 || second != 1 {
+		t.Errorf("got channels %d and %d, want 0 and 1", first, second)
+	}
+
+	// Scan mode can't have channel 0 while a helper runs on it
+	if _, err := dev.reserveChannel(0); !errors.Is(err, ErrChannelInUse) {
+		t.Errorf("got %v, want %v", err, ErrChannelInUse)
+	}
+
+	releaseFirst()
+	if channel, release, err := dev.freeChannel(); err != nil || channel != 0 {
+		t.Errorf("after release: got channel %d, %v, want 0", channel, err)
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * quality.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+// ChannelQuality counts the receive outcomes of a channel, from its data messages and RF events.
+type ChannelQuality struct {
+	Received       int // Data messages received
+	RxFail         int // EVENT_RX_FAIL, a message expected but not received
+	RxFailToSearch int // EVENT_RX_FAIL_GO_TO_SEARCH, the channel lost track of the master
+	SearchTimeout  int // EVENT_RX_SEARCH_TIMEOUT
+}
+
+// LinkQuality returns the percentage of expected messages that were received, from 0 to 100,
+// e.g. to show signal bars. It is 0 if nothing was expected yet.
+func (q ChannelQuality) LinkQuality() float64 {
+	expected := q.Received + q.RxFail
+	if expected == 0 {
+		return 0
+	}
+	return 100 * float64(q.Received) / float64(expected)
+}
+
+// ChannelQuality returns the receive counts of the channel since it was first used or reset.
+func (dev *Ant) ChannelQuality(channel uint8) ChannelQuality {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if q, ok := dev.quality[channel]; ok {
+		return *q
+	}
+	return ChannelQuality{}
+}
+
+// ResetChannelQuality clears the receive counts of the channel.
+func (dev *Ant) ResetChannelQuality(channel uint8) {
+	dev.mu.Lock()
+	delete(dev.quality, channel)
+	dev.mu.Unlock()
+}
+
+func (dev *Ant) trackQuality(msg *Message) {
+	var count func(q *ChannelQuality)
+
+	if e, ok := msg.AsChannelEvent(); ok {
+		if !e.IsRFEvent() {
+			return
+		}
+		switch e.Code {
+		case EVENT_RX_FAIL:
+			count = func(q *ChannelQuality) { q.RxFail++ }
+		case EVENT_RX_FAIL_GO_TO_SEARCH:
+			count = func(q *ChannelQuality) { q.RxFailToSearch++ }
+		case EVENT_RX_SEARCH_TIMEOUT:
+			count = func(q *ChannelQuality) { q.SearchTimeout++ }
+		default:
+			return
+		}
+	} else if msg.isChannelMessage() {
+		count = func(q *ChannelQuality) { q.Received++ }
+	} else {
+		return
+	}
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if dev.quality == nil {
+		dev.quality = make(map[uint8]*ChannelQuality)
+	}
+	q, ok := dev.quality[msg.Channel()]
+	if !ok {
+		q = &ChannelQuality{}
+		dev.quality[msg.Channel()] = q
+	}
+	count(q)
+}
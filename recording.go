@@ -0,0 +1,211 @@
+/*
+ * recording.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// A recording is a text file with a line per driver read or write, e.g. "1520000 R A4014F..."
+// for a read 1.52ms into the session: the time since Open in nanoseconds, R or W and the bytes in hex.
+
+// Recorder is a Driver logging all the reads and writes of another driver, with their timing,
+// so the session can be replayed with a Player.
+type Recorder struct {
+	driver Driver
+	mu     sync.Mutex
+	w      *bufio.Writer
+	start  time.Time
+	err    error
+}
+
+// NewRecorder returns a Recorder driving driver and writing the recording to w. w is flushed
+// on Close, but not closed.
+func NewRecorder(driver Driver, w io.Writer) *Recorder {
+	return &Recorder{driver: driver, w: bufio.NewWriter(w)}
+}
+
+func (r *Recorder) Open() error {
+	r.mu.Lock()
+	r.start = time.Now()
+	r.mu.Unlock()
+
+	return r.driver.Open()
+}
+
+func (r *Recorder) Close() {
+	r.driver.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.driver.Read(b)
+	if n > 0 {
+		r.record('R', b[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	n, err := r.driver.Write(b)
+	if n > 0 {
+		r.record('W', b[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) BufferSize() int {
+	return r.driver.BufferSize()
+}
+
+// SetLogger passes the logger on to the recorded driver if it takes one.
+func (r *Recorder) SetLogger(logger *log.Logger) {
+	if d, ok := r.driver.(interface{ SetLogger(*log.Logger) }); ok {
+		d.SetLogger(logger)
+	}
+}
+
+// Err returns the first error writing the recording, the session itself is not affected by them.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(op byte, b []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	_, r.err = fmt.Fprintf(r.w, "%d %c %X\n", time.Since(r.start).Nanoseconds(), op, b)
+}
+
+type recordedRead struct {
+	at   time.Duration
+	data []byte
+}
+
+// Player is a read only Driver replaying the reads of a recording made with a Recorder, each one
+// no sooner than it was read in the recorded session. Writes are discarded. Once all the reads have
+// been replayed Read returns no data.
+type Player struct {
+	reads      []recordedRead
+	bufferSize int
+
+	mu     sync.Mutex
+	start  time.Time
+	next   int
+	offset int // into the next read, if the buffer was too small for it
+}
+
+// NewPlayer reads a recording.
+func NewPlayer(recording io.Reader) (*Player, error) {
+	p := &Player{bufferSize: 64}
+
+	scanner := bufio.NewScanner(recording)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var ns int64
+		var op byte
+		var data string
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %c %s", &ns, &op, &data); err != nil {
+			return nil, fmt.Errorf("Invalid recording on line %d: %w", line, err)
+		}
+
+		b, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recording on line %d: %w", line, err)
+		}
+
+		switch op {
+		case 'R':
+			p.reads = append(p.reads, recordedRead{at: time.Duration(ns), data: b})
+		case 'W':
+			if len(b) > p.bufferSize {
+				p.bufferSize = len(b)
+			}
+		default:
+			return nil, fmt.Errorf("Invalid recording on line %d: unknown operation %q", line, op)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Open starts the replay from the beginning.
+func (p *Player) Open() error {
+	p.mu.Lock()
+	p.start = time.Now()
+	p.next, p.offset = 0, 0
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *Player) Close() {}
+
+// Read returns the next recorded read once it is due, or no data until then.
+func (p *Player) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next == len(p.reads) || time.Since(p.start) < p.reads[p.next].at {
+		return 0, nil
+	}
+
+	data := p.reads[p.next].data[p.offset:]
+	n := copy(b, data)
+	if n < len(data) {
+		// Hand out the rest with the next read
+		p.offset += n
+	} else {
+		p.next, p.offset = p.next+1, 0
+	}
+	return n, nil
+}
+
+func (p *Player) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// BufferSize returns the size of the largest recorded write, at least 64 bytes.
+func (p *Player) BufferSize() int {
+	return p.bufferSize
+}
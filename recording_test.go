@@ -0,0 +1,174 @@
+/*
+ * recording_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/purpl3F0x/go-ant/antplus/hrm"
+)
+
+// TestHRMSession replays the session of a heart rate monitor: the startup message, the responses
+// to the channel setup, a few HRM broadcasts and the channel closing, and checks what the
+// application sees of it. It doubles as an example of tracking a sensor on a channel.
+func TestHRMSession(t *testing.T) {
+	var script strings.Builder
+	read := func(at time.Duration, msg *Message) {
+		fmt.Fprintf(&script, "%d R %X\n", at.Nanoseconds(), []byte(msg.Encode()))
+	}
+	response := func(id uint8, code uint8) *Message {
+		return NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, id, code})
+	}
+	beat := func(toggle bool, count uint8, heartRate uint8) *Message {
+		payload := hrm.Encode(hrm.Data{
+			Page:               hrm.PagePreviousHeartBeat,
+			Toggle:             toggle,
+			HeartBeatEventTime: uint16(count) * 1024,
+			HeartBeatCount:     count,
+			ComputedHeartRate:  heartRate,
+		})
+		return NewMessage(MESG_BROADCAST_DATA_ID, append(Packet{0}, payload[:]...))
+	}
+
+	ms := time.Millisecond
+	read(5*ms, NewMessage(MESG_STARTUP_MESG_ID, Packet{RESET_POR}))
+	read(50*ms, response(MESG_ASSIGN_CHANNEL_ID, RESPONSE_NO_ERROR))
+	read(51*ms, response(MESG_CHANNEL_ID_ID, RESPONSE_NO_ERROR))
+	read(52*ms, response(MESG_CHANNEL_MESG_PERIOD_ID, RESPONSE_NO_ERROR))
+	read(53*ms, response(MESG_CHANNEL_RADIO_FREQ_ID, RESPONSE_NO_ERROR))
+	read(54*ms, response(MESG_OPEN_CHANNEL_ID, RESPONSE_NO_ERROR))
+	read(100*ms, beat(false, 1, 60))
+	read(110*ms, beat(false, 2, 61))
+	read(120*ms, beat(true, 3, 62))
+	read(130*ms, beat(true, 3, 62))
+	read(300*ms, response(MESG_CLOSE_CHANNEL_ID, RESPONSE_NO_ERROR))
+	read(301*ms, NewMessage(MESG_RESPONSE_EVENT_ID, Packet{0, MESG_EVENT_ID, EVENT_CHANNEL_CLOSED}))
+
+	player, err := NewPlayer(strings.NewReader(script.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev := MakeAnt(player, nil, WithSilent())
+
+	var mu sync.Mutex
+	var reasons []uint8
+	var events []ChannelEvent
+	var samples []hrm.Data
+	gotSamples := make(chan struct{})
+
+	dev.OnMessage(MESG_STARTUP_MESG_ID, func(msg *Message) {
+		mu.Lock()
+		reasons = append(reasons, msg.Data[0])
+		mu.Unlock()
+	})
+	dev.OnChannelEvent(0, func(e ChannelEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	dev.OnMessage(MESG_BROADCAST_DATA_ID, func(msg *Message) {
+		_, payload, ok := msg.AsBroadcastData()
+		if !ok {
+			t.Errorf("not broadcast data: %s", msg)
+			return
+		}
+		sample, ok := ProfileHeartRate.Decode(payload)
+		if !ok {
+			t.Errorf("HRM page not decoded: %s", msg)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if samples = append(samples, sample.(hrm.Data)); len(samples) == 4 {
+			close(gotSamples)
+		}
+	})
+
+	if err := dev.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Track any heart rate monitor on channel 0
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetChannelId(0, 0, hrm.DeviceType, 0)
+	dev.SetChannelPeriod(0, hrm.Period)
+	dev.SetChannelRFFreq(0, hrm.RFFreq)
+	if err := dev.OpenChannelSync(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-gotSamples:
+	case <-ctx.Done():
+		t.Fatal("HRM broadcasts not received")
+	}
+
+	if err := dev.CloseChannelSync(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reasons) != 1 || reasons[0] != RESET_POR {
+		t.Errorf("got startup reasons %v, want a power-on reset", reasons)
+	}
+
+	wantEvents := []ChannelEvent{
+		{0, MESG_ASSIGN_CHANNEL_ID, RESPONSE_NO_ERROR},
+		{0, MESG_CHANNEL_ID_ID, RESPONSE_NO_ERROR},
+		{0, MESG_CHANNEL_MESG_PERIOD_ID, RESPONSE_NO_ERROR},
+		{0, MESG_CHANNEL_RADIO_FREQ_ID, RESPONSE_NO_ERROR},
+		{0, MESG_OPEN_CHANNEL_ID, RESPONSE_NO_ERROR},
+		{0, MESG_CLOSE_CHANNEL_ID, RESPONSE_NO_ERROR},
+		{0, MESG_EVENT_ID, EVENT_CHANNEL_CLOSED},
+	}
+	if fmt.Sprint(events) != fmt.Sprint(wantEvents) {
+		t.Errorf("got channel events %v, want %v", events, wantEvents)
+	}
+
+	wantRates := []uint8{60, 61, 62, 62}
+	for i, s := range samples {
+		if s.Page != hrm.PagePreviousHeartBeat || s.ComputedHeartRate != wantRates[i] || s.Toggle != (i >= 2) {
+			t.Errorf("sample %d: got %+v, want page 4 at %d bpm", i, s, wantRates[i])
+		}
+	}
+
+	if last, ok := dev.LastMessage(0); !ok || last.Data[8] != 62 {
+		t.Errorf("got last message %v", last)
+	}
+	if q := dev.ChannelQuality(0); q.Received != 4 {
+		t.Errorf("got %d messages received, want 4", q.Received)
+	}
+}
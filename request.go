@@ -0,0 +1,136 @@
+/*
+ * request.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import "context"
+
+// requestKey identifies the response a RequestMessageSync waits for.
+type requestKey struct {
+	channel uint8 // 0 for messages not tied to a channel
+	id      uint8
+}
+
+func makeRequestKey(channel uint8, id uint8) requestKey {
+	if !isChannelMessageID(id) {
+		channel = 0
+	}
+	return requestKey{channel, id}
+}
+
+// pendingRequest is a RequestMessageSync waiting for its response.
+type pendingRequest struct {
+	response chan *Message
+	channel  uint8  // As sent in the request, which is what a rejection of it carries
+	seq      uint64 // Order the requests were made in
+}
+
+// RequestMessageSync requests a message from the module, e.g. MESG_CHANNEL_STATUS_ID or
+// MESG_CAPABILITIES_ID, and waits for it. If the module rejects the request it returns a
+// ResponseError, e.g. INVALID_MESSAGE for a message it doesn't know.
+//
+// Responses are matched by message ID, and by channel for channel messages (see Message.Channel).
+// Requests waiting for the same ID and channel get the responses in the order they were sent,
+// so concurrent calls don't steal each other's response. A message of that ID arriving unrequested
+// is taken by the oldest waiting request all the same. A rejection only tells the channel of the
+// request, it goes to the oldest request sent with that channel. Messages are still passed on to
+// the handlers and the read channel.
+func (dev *Ant) RequestMessageSync(ctx context.Context, channel uint8, messageId uint8) (*Message, error) {
+	key := makeRequestKey(channel, messageId)
+	request := &pendingRequest{response: make(chan *Message, 1), channel: channel}
+
+	dev.mu.Lock()
+	if dev.requests == nil {
+		dev.requests = make(map[requestKey][]*pendingRequest)
+	}
+	dev.requestSeq++
+	request.seq = dev.requestSeq
+	dev.requests[key] = append(dev.requests[key], request)
+	dev.mu.Unlock()
+
+	message := NewMessage(MESG_REQUEST_ID, Packet{channel, messageId})
+	err := dev.queueContext(ctx, PriorityControl, message)
+
+	if err == nil {
+		select {
+		case msg := <-request.response:
+			return requestResult(msg)
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// Give up the place in the queue, unless a response got in the way meanwhile
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	waiting := dev.requests[key]
+	for i := range waiting {
+		if waiting[i] == request {
+			dev.requests[key] = append(waiting[:i:i], waiting[i+1:]...)
+			return nil, err
+		}
+	}
+	return requestResult(<-request.response)
+}
+
+// requestResult turns what answered a request into the result of RequestMessageSync.
+func requestResult(msg *Message) (*Message, error) {
+	if e, ok := msg.AsChannelEvent(); ok {
+		return nil, ResponseError(e)
+	}
+	return msg, nil
+}
+
+// answerRequest hands a received message to the oldest request waiting for it, and the rejection
+// of a request to the oldest request sent with its channel.
+func (dev *Ant) answerRequest(msg *Message) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	key := makeRequestKey(msg.Channel(), msg.Id)
+	index := 0
+
+	if e, ok := msg.AsChannelEvent(); ok {
+		if e.MessageID != MESG_REQUEST_ID || e.Code == RESPONSE_NO_ERROR {
+			return
+		}
+
+		var oldest *pendingRequest
+		for k, waiting := range dev.requests {
+			for i, r := range waiting {
+				if r.channel == e.Channel && (oldest == nil || r.seq < oldest.seq) {
+					oldest, key, index = r, k, i
+				}
+			}
+		}
+		if oldest == nil {
+			return
+		}
+	}
+
+	waiting := dev.requests[key]
+	if len(waiting) <= index {
+		return
+	}
+	waiting[index].response <- msg
+	dev.requests[key] = append(waiting[:index:index], waiting[index+1:]...)
+}
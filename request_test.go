@@ -0,0 +1,95 @@
+/*
+ * request_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestMessageSyncRejected(t *testing.T) {
+	dev := startTestAnt(t, NewSimDriver())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// The simulated module doesn't know MESG_SERIAL_ERROR_ID
+	msg, err := dev.RequestMessageSync(ctx, 2, MESG_SERIAL_ERROR_ID)
+	var rejected ResponseError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("got %v, %v, want a ResponseError", msg, err)
+	}
+	if rejected.Channel != 2 || rejected.MessageID != MESG_REQUEST_ID || rejected.Code != INVALID_MESSAGE {
+		t.Errorf("got %+v, want INVALID_MESSAGE for the request on channel 2", rejected)
+	}
+
+	// The rejection was taken by that request only
+	if _, err := dev.RequestMessageSync(ctx, 0, MESG_CAPABILITIES_ID); err != nil {
+		t.Errorf("next request: %v", err)
+	}
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+	for key, waiting := range dev.requests {
+		if len(waiting) != 0 {
+			t.Errorf("%d requests left waiting for %+v", len(waiting), key)
+		}
+	}
+}
+
+func TestRequestRejectionGoesToOldest(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	results := make(map[uint8]chan error)
+	request := func(id uint8) {
+		results[id] = make(chan error, 1)
+		go func() {
+			_, err := dev.RequestMessageSync(ctx, 1, id)
+			results[id] <- err
+		}()
+	}
+	request(MESG_CHANNEL_STATUS_ID)
+	drv.waitWritten(t, MESG_REQUEST_ID)
+	request(MESG_CHANNEL_ID_ID)
+	for len(drv.written(t)) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The module rejects the first request and answers the second
+	drv.feed(
+		NewMessage(MESG_RESPONSE_EVENT_ID, Packet{1, MESG_REQUEST_ID, INVALID_MESSAGE}),
+		NewMessage(MESG_CHANNEL_ID_ID, Packet{1, 0x34, 0x12, 120, 1}),
+	)
+
+	var rejected ResponseError
+	if err := <-results[MESG_CHANNEL_STATUS_ID]; !errors.As(err, &rejected) {
+		t.Errorf("first request: got %v, want a ResponseError", err)
+	}
+	if err := <-results[MESG_CHANNEL_ID_ID]; err != nil {
+		t.Errorf("second request: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * scan.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DiscoveredDevice is a device seen by DiscoverDevices.
+type DiscoveredDevice struct {
+	ID       ChannelID
+	Profile  Profile // zero if no profile matched the device type, see ProfileFromDeviceType
+	RSSI     int     // in dBm of the last message, zero if the module doesn't report it
+	LastSeen time.Time
+}
+
+// DiscoverDevices opens channel 0 in RX scan mode and listens until ctx is done, then closes and
+// unassigns the channel, restores the lib config it changed, and returns the devices seen, one per
+// channel ID: devices sharing a device number but with a different device or transmission type
+// are reported separately. The ANT+ network key must have been set on network 0.
+// Channel 0 must be free, if it is assigned or used by another helper ErrChannelInUse is
+// returned and it is left alone.
+// If profiles are given only devices of their types are reported and the first profile's
+// RF frequency is scanned, otherwise every device on RFFreqANTPlus is, labelled with its
+// standard ANT+ profile when it has one.
+func (dev *Ant) DiscoverDevices(ctx context.Context, profiles ...Profile) ([]DiscoveredDevice, error) {
+	if c, ok := dev.Capabilities(); ok && c.AdvancedOptions2&CAPABILITIES_SCAN_MODE_ENABLED == 0 {
+		return nil, ErrUnsupportedByDevice
+	}
+
+	rfFreq := RFFreqANTPlus
+	if len(profiles) > 0 {
+		rfFreq = profiles[0].RFFreq
+	}
+
+	var mu sync.Mutex
+	found := make(map[ChannelID]DiscoveredDevice)
+
+	seen := func(msg *Message) {
+		e, ok := msg.ExtendedData()
+		if !ok || !e.HasChannelID() {
+			return
+		}
+
+		d := DiscoveredDevice{ID: e.ChannelID, LastSeen: msg.Received}
+		d.RSSI, _ = e.RSSIdBm()
+		if len(profiles) > 0 {
+			if d.Profile, ok = matchProfile(profiles, e.ChannelID.DeviceType); !ok {
+				return
+			}
+		} else {
+			d.Profile, _ = ProfileFromDeviceType(e.ChannelID.DeviceType)
+		}
+
+		mu.Lock()
+		found[d.ID] = d
+		mu.Unlock()
+	}
+
+	for _, id := range []uint8{
+		MESG_BROADCAST_DATA_ID, MESG_ACKNOWLEDGED_DATA_ID, MESG_BURST_DATA_ID,
+		MESG_EXT_BROADCAST_DATA_ID, MESG_EXT_ACKNOWLEDGED_DATA_ID, MESG_EXT_BURST_DATA_ID,
+	} {
+		defer dev.OnMessage(id, seen)()
+	}
+
+	// Scan mode only works on channel 0, which may well be tracking something already
+	release, err := dev.reserveChannel(0)
+	if err != nil {
+		return nil, fmt.Errorf("Scan mode needs channel 0: %w", err)
+	}
+	defer release()
+
+	dev.mu.Lock()
+	libConfig := dev.libConfig
+	dev.mu.Unlock()
+
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		return nil, err
+	}
+	dev.SetChannelId(0, 0, 0, 0)
+	dev.SetChannelRFFreq(0, rfFreq)
+	dev.SetLibConfig(ANT_LIB_CONFIG_MESG_OUT_INC_DEVICE_ID | ANT_LIB_CONFIG_MESG_OUT_INC_RSSI)
+	dev.OpenRxScanMode()
+
+	<-ctx.Done()
+
+	// Channel 0 was free, leave it so and the lib config as it was
+	closeCtx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	err = dev.CloseChannelSync(closeCtx, 0)
+	dev.UnAssignChannel(0)
+	dev.SetLibConfig(libConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	devices := make([]DiscoveredDevice, 0, len(found))
+	for _, d := range found {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		a, b := devices[i].ID, devices[j].ID
+		if a.DeviceNumber != b.DeviceNumber {
+			return a.DeviceNumber < b.DeviceNumber
+		}
+		if a.DeviceType != b.DeviceType {
+			return a.DeviceType < b.DeviceType
+		}
+		return a.TransmissionType < b.TransmissionType
+	})
+
+	return devices, nil
+}
+
+func matchProfile(profiles []Profile, deviceType uint8) (Profile, bool) {
+	deviceType &^= ANT_ID_DEVICE_TYPE_PAIRING_FLAG
+	for _, p := range profiles {
+		if p.DeviceType == deviceType {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
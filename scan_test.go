@@ -0,0 +1,84 @@
+/*
+ * scan_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiscoverDevicesReleasesChannel(t *testing.T) {
+	dev := startTestAnt(t, NewSimDriver())
+	dev.SetLibConfig(ANT_LIB_CONFIG_MESG_OUT_INC_RSSI)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := dev.DiscoverDevices(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := dev.ExportConfig(); len(c.Channels) != 0 {
+		t.Errorf("channels left assigned: %+v", c.Channels)
+	}
+	dev.mu.Lock()
+	libConfig := dev.libConfig
+	dev.mu.Unlock()
+	if libConfig != ANT_LIB_CONFIG_MESG_OUT_INC_RSSI {
+		t.Errorf("got lib config 0x%02X, want 0x%02X", libConfig, ANT_LIB_CONFIG_MESG_OUT_INC_RSSI)
+	}
+
+	// Channel 0 can be used again right away
+	syncCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := dev.OpenChannelSync(syncCtx, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverDevicesLeavesAssignedChannel(t *testing.T) {
+	drv := &testDriver{}
+	dev := startTestAnt(t, drv)
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetChannelId(0, 0, 120, 0)
+	dev.OpenChannel(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := dev.DiscoverDevices(ctx); !errors.Is(err, ErrChannelInUse) {
+		t.Errorf("got %v, want %v", err, ErrChannelInUse)
+	}
+
+	dev.Stop()
+	for _, msg := range drv.written(t)[3:] {
+		t.Errorf("%s sent", MessageName(msg.Id))
+	}
+	if c := dev.ExportConfig(); len(c.Channels) != 1 || !c.Channels[0].Open || c.Channels[0].ID == nil {
+		t.Errorf("channel 0 changed: %+v", c.Channels)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * scheduler.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import "sync"
+
+// Scheduler feeds several transmit channels from one place: on every EVENT_TX of a channel
+// it broadcasts the next payload of that channel's provider, e.g. to emulate multiple sensors.
+type Scheduler struct {
+	dev *Ant
+
+	mu       sync.Mutex
+	channels map[uint8]func() // Unregisters the event handler of each scheduled channel
+}
+
+// NewScheduler creates a Scheduler, Close releases it.
+func (dev *Ant) NewScheduler() *Scheduler {
+	return &Scheduler{dev: dev, channels: make(map[uint8]func())}
+}
+
+// Set makes next the payload provider of the channel, replacing any previous one, and broadcasts
+// its first payload right away so the coming period isn't empty. Like OpenMasterChannel's, next is
+// called from the decoding goroutine and must not block. The provider is dropped when the channel closes.
+func (s *Scheduler) Set(channel uint8, next func() [8]byte) {
+	s.Remove(channel)
+
+	remove := s.dev.OnChannelEvent(channel, func(e ChannelEvent) {
+		if !e.IsRFEvent() {
+			return
+		}
+
+		switch e.Code {
+		case EVENT_TX:
+			payload := next()
+			s.dev.SendBroadcastData(channel, payload[:])
+		case EVENT_CHANNEL_CLOSED:
+			s.Remove(channel)
+		}
+	})
+
+	s.mu.Lock()
+	s.channels[channel] = remove
+	s.mu.Unlock()
+
+	payload := next()
+	s.dev.SendBroadcastData(channel, payload[:])
+}
+
+// Remove drops the payload provider of the channel, the module keeps broadcasting the last payload.
+func (s *Scheduler) Remove(channel uint8) {
+	s.mu.Lock()
+	remove := s.channels[channel]
+	delete(s.channels, channel)
+	s.mu.Unlock()
+
+	if remove != nil {
+		remove()
+	}
+}
+
+// Close drops every provider.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	channels := s.channels
+	s.channels = make(map[uint8]func())
+	s.mu.Unlock()
+
+	for _, remove := range channels {
+		remove()
+	}
+}
+
+// SplitBroadcast cuts data into 8 byte payloads, the last one padded with zeros, to be sent on
+// successive EVENT_TX, e.g. from a Scheduler provider. Unlike a burst transfer the payloads are
+// sent one per channel period, without flow control. It returns nil for empty data.
+func SplitBroadcast(data []byte) [][8]byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	frames := make([][8]byte, (len(data)+7)/8)
+	for i := range frames {
+		copy(frames[i][:], data[i*8:])
+	}
+	return frames
+}
@@ -0,0 +1,91 @@
+/*
+ * shared.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// Shared channel addressing
+//
+// On a shared bidirectional channel a single master talks to many slaves in turn. Every payload
+// starts with the shared address of the slave it is meant for, or that sent it, either on 1 or
+// on 2 bytes (little-endian), leaving 7 or 6 bytes of data. The address size is set by the two
+// lower bits of the transmission type: ANT_TRANS_TYPE_1_BYTE_SHARED_ADDRESS or
+// ANT_TRANS_TYPE_2_BYTE_SHARED_ADDRESS. Each slave is given its address with SetSharedAddress
+// and only passes on the messages carrying it.
+
+// AssignSharedChannel assigns a shared bidirectional channel, as the master or as a slave.
+// Like AssignChannel it returns ErrNetworkKeyNotSet for a network without key.
+func (dev *Ant) AssignSharedChannel(channel uint8, master bool, networkNumber uint8) error {
+	channelType := ChannelTypeSharedBidirectionalSlave
+	if master {
+		channelType = ChannelTypeSharedBidirectionalMaster
+	}
+	return dev.AssignChannel(channel, channelType, networkNumber)
+}
+
+// SetSharedAddress sets the address a slave answers to on a shared channel.
+func (dev *Ant) SetSharedAddress(channel uint8, address uint16) {
+	_ = dev.SetSharedAddressContext(context.Background(), channel, address)
+}
+
+// SetSharedAddressContext is SetSharedAddress giving up when ctx is done.
+func (dev *Ant) SetSharedAddressContext(ctx context.Context, channel uint8, address uint16) error {
+	payload := [3]byte{channel}
+	binary.LittleEndian.PutUint16(payload[1:], address)
+
+	message := NewMessage(MESG_SET_SHARED_ADDRESS_ID, payload[:])
+	return dev.queueContext(ctx, PriorityConfig, message)
+}
+
+// MakeSharedPayload builds the payload of a shared channel message for the given address.
+// data must fit in the bytes left by the address, 6 with a 2-byte address and 7 otherwise,
+// the rest is zero. It panics if it doesn't, or if address doesn't fit in a single byte.
+func MakeSharedPayload(twoByteAddress bool, address uint16, data []byte) (payload [8]byte) {
+	offset := 1
+	if twoByteAddress {
+		offset = 2
+		binary.LittleEndian.PutUint16(payload[:], address)
+	} else {
+		if address > 0xFF {
+			panic(fmt.Sprint("Shared address should fit in 1 byte not ", address))
+		}
+		payload[0] = uint8(address)
+	}
+
+	if len(data) > len(payload)-offset {
+		panic(fmt.Sprintf("Shared data length should be at most %d not %d", len(payload)-offset, len(data)))
+	}
+	copy(payload[offset:], data)
+	return payload
+}
+
+// SharedAddress returns the address a shared channel payload carries, and the data after it.
+func SharedAddress(twoByteAddress bool, payload [8]byte) (address uint16, data []byte) {
+	if twoByteAddress {
+		return binary.LittleEndian.Uint16(payload[:]), payload[2:]
+	}
+	return uint16(payload[0]), payload[1:]
+}
@@ -0,0 +1,275 @@
+/*
+ * sim.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// SimSensor is a device simulated by a SimDriver.
+type SimSensor struct {
+	ID      ChannelID
+	Payload func() [8]byte // Called for every broadcast, e.g. hrm.Encode of a changing heart rate
+}
+
+// SimDriver is a Driver simulating a module, to run whole flows without hardware. It answers
+// a reset with a startup message, requests for the capabilities, version, serial number and
+// channel ID, a zero channel period with INVALID_MESSAGE, and every other command with
+// RESPONSE_NO_ERROR. Once a slave channel is opened with a channel ID matching a simulated
+// sensor, the sensor's payload is broadcast every channel period. Closing a channel reports
+// EVENT_CHANNEL_CLOSED, and acknowledged data is reported EVENT_TRANSFER_TX_COMPLETED on
+// channels tracking a sensor, EVENT_TRANSFER_TX_FAILED otherwise.
+type SimDriver struct {
+	Capabilities Capabilities
+	Version      string
+	SerialNumber uint32
+
+	sensors []SimSensor
+
+	mu       sync.Mutex
+	in       Decoder
+	out      Packet
+	channels map[uint8]*simChannel
+}
+
+type simChannel struct {
+	id     ChannelID
+	period uint16
+	open   bool
+	sensor *SimSensor
+	next   time.Time
+}
+
+// NewSimDriver returns a simulated module, with 8 channels and networks and support for
+// extended messages and scan mode, surrounded by the given sensors.
+func NewSimDriver(sensors ...SimSensor) *SimDriver {
+	return &SimDriver{
+		Capabilities: Capabilities{
+			MaxChannels:      8,
+			MaxNetworks:      8,
+			AdvancedOptions:  CAPABILITIES_SERIAL_NUMBER_ENABLED | CAPABILITIES_PER_CHANNEL_TX_POWER_ENABLED,
+			AdvancedOptions2: CAPABILITIES_EXT_MESSAGE_ENABLED | CAPABILITIES_SCAN_MODE_ENABLED | CAPABILITIES_EXT_ASSIGN_ENABLED,
+		},
+		Version:      "SIM1.00",
+		SerialNumber: 1,
+		sensors:      sensors,
+		channels:     make(map[uint8]*simChannel),
+	}
+}
+
+func (d *SimDriver) Open() error { return nil }
+
+func (d *SimDriver) Close() {}
+
+func (d *SimDriver) BufferSize() int { return 64 }
+
+// Read returns the pending responses and the broadcasts that are due, or no data.
+func (d *SimDriver) Read(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for channel, c := range d.channels {
+		if !c.open || c.sensor == nil {
+			continue
+		}
+
+		period := time.Duration(c.period) * time.Second / PeriodClock
+		if now.Sub(c.next) > period {
+			// Don't catch up on the periods nobody read
+			c.next = now
+		}
+		for !c.next.After(now) {
+			payload := c.sensor.Payload()
+			d.reply(MESG_BROADCAST_DATA_ID, append(Packet{channel}, payload[:]...))
+			c.next = c.next.Add(period)
+		}
+	}
+
+	n := copy(b, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// Write processes the commands of the host.
+func (d *SimDriver) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, _ = d.in.Write(b)
+	for {
+		frame, msg, _ := d.in.next()
+		if frame == nil {
+			return len(b), nil
+		}
+		if msg != nil {
+			d.handle(msg)
+		}
+	}
+}
+
+func (d *SimDriver) reply(id uint8, data Packet) {
+	d.out = append(d.out, NewMessage(id, data).Encode()...)
+}
+
+func (d *SimDriver) respond(channel uint8, id uint8, code uint8) {
+	d.reply(MESG_RESPONSE_EVENT_ID, Packet{channel, id, code})
+}
+
+func (d *SimDriver) event(channel uint8, code uint8) {
+	d.respond(channel, MESG_EVENT_ID, code)
+}
+
+func (d *SimDriver) handle(msg *Message) {
+	var channel uint8
+	if len(msg.Data) > 0 {
+		channel = msg.Data[0]
+	}
+	c := d.channels[channel]
+
+	switch msg.Id {
+	case MESG_SYSTEM_RESET_ID:
+		d.channels = make(map[uint8]*simChannel)
+		d.reply(MESG_STARTUP_MESG_ID, Packet{RESET_CMD})
+		return
+
+	case MESG_REQUEST_ID:
+		if len(msg.Data) >= 2 {
+			d.request(channel, msg.Data[1])
+		}
+		return
+
+	case MESG_BROADCAST_DATA_ID, MESG_BURST_DATA_ID:
+		return
+
+	case MESG_ACKNOWLEDGED_DATA_ID:
+		if c != nil && c.open && c.sensor != nil {
+			d.event(channel, EVENT_TRANSFER_TX_COMPLETED)
+		} else {
+			d.event(channel, EVENT_TRANSFER_TX_FAILED)
+		}
+		return
+
+	case MESG_ASSIGN_CHANNEL_ID:
+		d.channels[channel] = &simChannel{period: uint16(defaultPeriod)}
+
+	case MESG_UNASSIGN_CHANNEL_ID:
+		delete(d.channels, channel)
+
+	case MESG_CHANNEL_ID_ID:
+		if c != nil && len(msg.Data) >= MESG_CHANNEL_ID_SIZE {
+			c.id = channelIDFromBytes(msg.Data[1:])
+		}
+
+	case MESG_CHANNEL_MESG_PERIOD_ID:
+		if c != nil && len(msg.Data) >= MESG_CHANNEL_MESG_PERIOD_SIZE {
+			period := binary.LittleEndian.Uint16(msg.Data[1:])
+			if period == 0 {
+				// A zero period would broadcast endlessly
+				d.respond(channel, msg.Id, INVALID_MESSAGE)
+				return
+			}
+			c.period = period
+		}
+
+	case MESG_OPEN_CHANNEL_ID:
+		if c == nil {
+			d.respond(channel, msg.Id, CHANNEL_IN_WRONG_STATE)
+			return
+		}
+		c.open = true
+		c.sensor = d.match(c.id)
+		c.next = time.Now()
+
+	case MESG_OPEN_RX_SCAN_ID:
+		if c == nil {
+			d.respond(channel, msg.Id, CHANNEL_IN_WRONG_STATE)
+			return
+		}
+		c.open = true
+
+	case MESG_CLOSE_CHANNEL_ID:
+		if c == nil || !c.open {
+			d.respond(channel, msg.Id, CHANNEL_IN_WRONG_STATE)
+			return
+		}
+		c.open = false
+		d.respond(channel, msg.Id, RESPONSE_NO_ERROR)
+		d.event(channel, EVENT_CHANNEL_CLOSED)
+		return
+	}
+
+	d.respond(channel, msg.Id, RESPONSE_NO_ERROR)
+}
+
+func (d *SimDriver) request(channel uint8, id uint8) {
+	switch id {
+	case MESG_CAPABILITIES_ID:
+		c := d.Capabilities
+		d.reply(id, Packet{
+			c.MaxChannels, c.MaxNetworks, c.StandardOptions, c.AdvancedOptions,
+			c.AdvancedOptions2, c.MaxSensRcoreChannels, c.AdvancedOptions3, c.AdvancedOptions4,
+		})
+
+	case MESG_VERSION_ID:
+		d.reply(id, append(Packet(d.Version), 0))
+
+	case MESG_GET_SERIAL_NUM_ID:
+		payload := make(Packet, 4)
+		binary.LittleEndian.PutUint32(payload, d.SerialNumber)
+		d.reply(id, payload)
+
+	case MESG_CHANNEL_ID_ID:
+		c, ok := d.channels[channel]
+		if !ok {
+			d.respond(channel, MESG_REQUEST_ID, CHANNEL_IN_WRONG_STATE)
+			return
+		}
+		id := c.id
+		if c.sensor != nil {
+			id = c.sensor.ID
+		}
+		payload := Packet{channel, 0, 0, id.DeviceType, id.TransmissionType}
+		binary.LittleEndian.PutUint16(payload[1:], id.DeviceNumber)
+		d.reply(MESG_CHANNEL_ID_ID, payload)
+
+	default:
+		d.respond(channel, MESG_REQUEST_ID, INVALID_MESSAGE)
+	}
+}
+
+// match returns the sensor a slave channel with the given ID pairs with, zero fields being wildcards.
+func (d *SimDriver) match(id ChannelID) *SimSensor {
+	deviceType := id.DeviceType &^ ANT_ID_DEVICE_TYPE_PAIRING_FLAG
+
+	for i := range d.sensors {
+		s := d.sensors[i].ID
+		if (id.DeviceNumber == 0 || id.DeviceNumber == s.DeviceNumber) &&
+			(deviceType == 0 || deviceType == s.DeviceType&^ANT_ID_DEVICE_TYPE_PAIRING_FLAG) &&
+			(id.TransmissionType == 0 || id.TransmissionType == s.TransmissionType) {
+			return &d.sensors[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+ * sim_test.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimDriverZeroPeriod(t *testing.T) {
+	sim := NewSimDriver(SimSensor{
+		ID:      ChannelID{DeviceNumber: 1, DeviceType: 120, TransmissionType: 1},
+		Payload: func() (payload [8]byte) { return payload },
+	})
+	dev := startTestAnt(t, sim)
+
+	rejected := make(chan uint8, 1)
+	dev.OnChannelEvent(0, func(e ChannelEvent) {
+		if e.MessageID == MESG_CHANNEL_MESG_PERIOD_ID {
+			rejected <- e.Code
+		}
+	})
+	received := make(chan struct{}, 1)
+	dev.OnMessage(MESG_BROADCAST_DATA_ID, func(*Message) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := dev.AssignChannel(0, ChannelTypeBidirectionalSlave, 0); err != nil {
+		t.Fatal(err)
+	}
+	dev.SetChannelId(0, 0, 120, 0)
+	dev.SetChannelPeriod(0, 0)
+	select {
+	case code := <-rejected:
+		if code != INVALID_MESSAGE {
+			t.Errorf("got response %s, want INVALID_MESSAGE", ResponseCodeName(code))
+		}
+	case <-ctx.Done():
+		t.Fatal("zero period not answered")
+	}
+
+	// The channel keeps broadcasting at the default period
+	if err := dev.OpenChannelSync(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Fatal("no broadcast")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * timing.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"math"
+	"time"
+)
+
+// defaultPeriod is the channel period of a module that wasn't given one.
+const defaultPeriod Period = 8192
+
+// ChannelTiming are reception statistics of a channel, measured from the arrival time of
+// its data messages against the channel period.
+type ChannelTiming struct {
+	Period       time.Duration // Expected interval, from the configured channel period
+	Messages     int           // Data messages received
+	Dropped      int           // Periods that passed without a message
+	MeanInterval time.Duration // Mean time between two received messages
+	MaxInterval  time.Duration
+	Jitter       time.Duration // Mean deviation of the arrival times from the period grid
+}
+
+type channelTiming struct {
+	last      time.Time
+	messages  int
+	dropped   int
+	intervals time.Duration
+	deviation time.Duration
+	max       time.Duration
+}
+
+// ChannelTiming returns the reception statistics of the channel, ok is false if nothing has been received on it.
+func (dev *Ant) ChannelTiming(channel uint8) (t ChannelTiming, ok bool) {
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	timing, ok := dev.timing[channel]
+	if !ok {
+		return t, false
+	}
+
+	t.Period = dev.channelPeriod(channel)
+	t.Messages = timing.messages
+	t.Dropped = timing.dropped
+	t.MaxInterval = timing.max
+	if n := timing.messages - 1; n > 0 {
+		t.MeanInterval = timing.intervals / time.Duration(n)
+		t.Jitter = timing.deviation / time.Duration(n)
+	}
+	return t, true
+}
+
+// ResetChannelTiming clears the reception statistics of the channel.
+func (dev *Ant) ResetChannelTiming(channel uint8) {
+	dev.mu.Lock()
+	delete(dev.timing, channel)
+	dev.mu.Unlock()
+}
+
+// channelPeriod returns the configured period of the channel as a duration, dev.mu must be held.
+func (dev *Ant) channelPeriod(channel uint8) time.Duration {
+	period := defaultPeriod
+	if c, ok := dev.channels[channel]; ok && c.Period != nil && *c.Period != 0 {
+		period = Period(*c.Period)
+	}
+	return time.Duration(period) * time.Second / PeriodClock
+}
+
+func (dev *Ant) trackTiming(msg *Message) {
+	if len(msg.Data) == 0 {
+		return
+	}
+	channel := msg.Channel()
+
+	dev.mu.Lock()
+	defer dev.mu.Unlock()
+
+	if dev.timing == nil {
+		dev.timing = make(map[uint8]*channelTiming)
+	}
+
+	timing, ok := dev.timing[channel]
+	if !ok {
+		timing = &channelTiming{}
+		dev.timing[channel] = timing
+	}
+
+	if timing.messages > 0 {
+		interval := msg.Received.Sub(timing.last)
+		period := dev.channelPeriod(channel)
+
+		// A message is expected every period, the missing ones show up as whole periods in the interval
+		periods := math.Round(float64(interval) / float64(period))
+		if periods > 1 {
+			timing.dropped += int(periods) - 1
+		}
+		if periods < 1 {
+			periods = 1
+		}
+		deviation := interval - time.Duration(periods)*period
+		if deviation < 0 {
+			deviation = -deviation
+		}
+
+		timing.intervals += interval
+		timing.deviation += deviation
+		if interval > timing.max {
+			timing.max = interval
+		}
+	}
+
+	timing.last = msg.Received
+	timing.messages++
+}
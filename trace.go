@@ -0,0 +1,72 @@
+/*
+ * trace.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"sync"
+	"time"
+)
+
+// TracedFrame is a frame recorded by the trace buffer, see WithTraceBuffer.
+type TracedFrame struct {
+	Time     time.Time
+	Outgoing bool   // Written to the driver, otherwise read from it
+	Frame    Packet // The raw frame, sync byte and checksum included
+}
+
+// traceBuffer is a ring buffer of the last frames.
+type traceBuffer struct {
+	mu     sync.Mutex
+	frames []TracedFrame
+	next   int
+	full   bool
+}
+
+func (t *traceBuffer) record(outgoing bool, frame Packet) {
+	f := TracedFrame{Time: time.Now(), Outgoing: outgoing, Frame: append(Packet(nil), frame...)}
+
+	t.mu.Lock()
+	t.frames[t.next] = f
+	t.next++
+	if t.next == len(t.frames) {
+		t.next = 0
+		t.full = true
+	}
+	t.mu.Unlock()
+}
+
+// Trace returns the frames recorded by the trace buffer, oldest first. It returns nil if the
+// Ant was made without WithTraceBuffer.
+func (dev *Ant) Trace() []TracedFrame {
+	t := dev.trace
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.full {
+		return append([]TracedFrame(nil), t.frames[:t.next]...)
+	}
+	return append(append([]TracedFrame(nil), t.frames[t.next:]...), t.frames[:t.next]...)
+}
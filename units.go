@@ -0,0 +1,103 @@
+/*
+ * units.go
+ *
+ * Copyright (c) 2021-2023 Stavros Avramidis (@purpl3F0x). All rights reserved.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ *
+ */
+
+package ant
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// PeriodClock is the ANT timebase; channel periods are counted in ticks of 1/32768s.
+const PeriodClock = 32768
+
+// Period is a channel message period in 1/32768s counts, as taken by SetChannelPeriod.
+type Period uint16
+
+// Hz returns the message rate of the period, e.g. 4.06Hz for 8070.
+func (p Period) Hz() float64 {
+	if p == 0 {
+		return 0
+	}
+	return PeriodClock / float64(p)
+}
+
+// PeriodFromHz converts a message rate to the nearest channel period count,
+// e.g. 4Hz to 8192. It panics if the rate does not fit a period (roughly 0.5Hz to 32768Hz).
+func PeriodFromHz(hz float64) uint16 {
+	period, ok := periodFromHz(hz)
+	if !ok {
+		panic(fmt.Sprint("Frequency out of the channel period range: ", hz, "Hz"))
+	}
+
+	return period
+}
+
+func periodFromHz(hz float64) (uint16, bool) {
+	counts := math.Round(PeriodClock / hz)
+
+	if !(counts >= 1 && counts <= math.MaxUint16) {
+		return 0, false
+	}
+	return uint16(counts), true
+}
+
+const (
+	RFFreqBaseMHz = 2400 // RF frequency offsets are counted in 1MHz steps from 2400MHz
+	RFFreqMaxMHz  = 2524 // Highest frequency an ANT radio can be tuned to
+
+	RFFreqANTPlus uint8 = 57 // 2457MHz, the ANT+ frequency
+)
+
+// RFFreqFromMHz converts a frequency in MHz to the offset byte taken by SetChannelRFFreq,
+// e.g. 2457 to 57. It panics if the frequency is outside of the 2400-2524MHz band.
+func RFFreqFromMHz(mhz int) uint8 {
+	if mhz < RFFreqBaseMHz || mhz > RFFreqMaxMHz {
+		panic(fmt.Sprint("RF frequency should be between 2400MHz and 2524MHz not ", mhz, "MHz"))
+	}
+
+	return uint8(mhz - RFFreqBaseMHz)
+}
+
+const (
+	SearchTimeoutDisabled uint8 = 0x00 // No high priority search, the channel times out immediately
+	SearchTimeoutInfinite uint8 = 0xFF // Search until a device is found (not supported by all modules)
+
+	// SearchTimeoutUnit is the resolution of the search timeout taken by SetChannelSearchTimeout
+	SearchTimeoutUnit = 2500 * time.Millisecond
+)
+
+// SearchTimeoutFromDuration converts a duration to the nearest search timeout count, in 2.5s units.
+// It panics if the duration is not positive or longer than the largest finite timeout (254 counts,
+// 635s); use SearchTimeoutInfinite to search forever.
+func SearchTimeoutFromDuration(d time.Duration) uint8 {
+	counts := math.Round(float64(d) / float64(SearchTimeoutUnit))
+
+	if d <= 0 || counts >= float64(SearchTimeoutInfinite) {
+		panic(fmt.Sprint("Search timeout should be between 0s and 635s not ", d))
+	}
+	if counts < 1 {
+		counts = 1
+	}
+
+	return uint8(counts)
+}